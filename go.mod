@@ -11,4 +11,5 @@ require (
 	k8s.io/client-go v0.17.0
 	k8s.io/klog v1.0.0
 	k8s.io/utils v0.0.0-20200109141947-94aeca20bf09 // indirect
+	sigs.k8s.io/yaml v1.1.0
 )
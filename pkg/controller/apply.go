@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ErrApplyClientNotSet is returned by Context.Apply() when the factory was not given the client to apply with.
+var ErrApplyClientNotSet = errors.New("apply client is not set, use Factory.WithApplyClient()")
+
+// ApplyOptions configures the server-side apply done via Context.Apply().
+type ApplyOptions struct {
+	// Force makes the controller take over the fields that are managed by other field managers. Without force, applying
+	// fields managed by others fails with conflict error (errors.IsConflict() returns true) and nothing is changed.
+	Force bool
+}
+
+// Apply does server-side apply of the given object with the controller name as the field manager.
+// The object must have apiVersion, kind and name set. It returns the object as stored on the server and true when the
+// apply created or changed the object. The event is recorded only when the object was changed.
+// Server-side apply that does not change anything keeps the resourceVersion, so the object is first applied with dry
+// run, which returns the current resourceVersion or none when the object does not exist, and the change is detected by
+// comparing it with the resourceVersion of the applied object.
+func (c controllerContext) Apply(resource schema.GroupVersionResource, obj *unstructured.Unstructured, options ApplyOptions) (*unstructured.Unstructured, bool, error) {
+	if c.applyClient == nil {
+		return nil, false, ErrApplyClientNotSet
+	}
+	if len(obj.GetAPIVersion()) == 0 || len(obj.GetKind()) == 0 || len(obj.GetName()) == 0 {
+		return nil, false, fmt.Errorf("unable to apply %s: apiVersion, kind and name must be set", resource.Resource)
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, false, err
+	}
+
+	force := options.Force
+	client := c.applyClient.Resource(resource).Namespace(obj.GetNamespace())
+	current, err := client.Patch(obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{FieldManager: c.fieldManager(), Force: &force, DryRun: []string{metav1.DryRunAll}})
+	if err != nil {
+		// the error is returned as it is, so the callers can check for conflicts
+		return nil, false, err
+	}
+	result, err := client.Patch(obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{FieldManager: c.fieldManager(), Force: &force})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// the write is recorded only when the synced object was applied, under its key, as the unstructured result does not
+	// match the copies of the other objects delivered by typed informers
+	if c.isQueueObject(result) && c.writes != nil {
+		c.writes.written(objectKeyFor(c.queueObject), result.GetResourceVersion())
+	}
+
+	key := queueKeyFor(result)
+	switch current.GetResourceVersion() {
+	case result.GetResourceVersion():
+		return result, false, nil
+	case "":
+		c.Events().Eventf(obj.GetKind()+"Created", "Created %s %q because it was missing", obj.GetKind(), key)
+	default:
+		c.Events().Eventf(obj.GetKind()+"Updated", "Updated %s %q because it changed", obj.GetKind(), key)
+	}
+	return result, true, nil
+}
+
+// fieldManager returns the field manager used for server-side apply, which is the controller name.
+func (c controllerContext) fieldManager() string {
+	return c.ControllerName()
+}
+
+// isSameKind returns true when the object is of the same kind as the applied object. The objects delivered by typed
+// informers have no TypeMeta, in that case the kind is compared with the Go type name.
+func isSameKind(obj runtime.Object, applied *unstructured.Unstructured) bool {
+	if gvk := obj.GetObjectKind().GroupVersionKind(); len(gvk.Kind) > 0 {
+		return gvk.GroupKind() == applied.GroupVersionKind().GroupKind()
+	}
+	return objectKind(obj) == applied.GetKind()
+}
@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// fakeApplyServer stores single config map and implements simplified server-side apply: the data are owned by other
+// field manager when conflicting is set. Like the real server, the dry run returns the object without resourceVersion
+// when it does not exist and keeps the current resourceVersion otherwise.
+type fakeApplyServer struct {
+	lock          sync.Mutex
+	stored        *unstructured.Unstructured
+	conflicting   bool
+	fieldManagers []string
+	methods       []string
+	dryRuns       int
+}
+
+func (s *fakeApplyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	writeStatus := func(status *apierrors.StatusError) {
+		w.WriteHeader(int(status.ErrStatus.Code))
+		json.NewEncoder(w).Encode(status.ErrStatus)
+	}
+	resource := schema.GroupResource{Resource: "configmaps"}
+	s.methods = append(s.methods, r.Method)
+
+	switch r.Method {
+	case http.MethodPatch:
+		if r.Header.Get("Content-Type") != string(types.ApplyPatchType) {
+			writeStatus(apierrors.NewBadRequest("unexpected patch type " + r.Header.Get("Content-Type")))
+			return
+		}
+		s.fieldManagers = append(s.fieldManagers, r.URL.Query().Get("fieldManager"))
+		if s.conflicting && r.URL.Query().Get("force") != "true" {
+			writeStatus(apierrors.NewConflict(resource, "test-config", nil))
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		applied := &unstructured.Unstructured{}
+		if err := applied.UnmarshalJSON(body); err != nil {
+			writeStatus(apierrors.NewBadRequest(err.Error()))
+			return
+		}
+		if r.URL.Query().Get("dryRun") == meta.DryRunAll {
+			s.dryRuns++
+			if s.stored != nil {
+				applied.SetResourceVersion(s.stored.GetResourceVersion())
+			}
+			data, _ := applied.MarshalJSON()
+			w.Write(data)
+			return
+		}
+		s.conflicting = false
+		if s.stored == nil || !reflect.DeepEqual(s.stored.Object["data"], applied.Object["data"]) {
+			resourceVersion := 1
+			created := meta.NewTime(time.Now().Truncate(time.Second))
+			updated := created
+			if s.stored != nil {
+				resourceVersion, _ = strconv.Atoi(s.stored.GetResourceVersion())
+				resourceVersion++
+				created = s.stored.GetCreationTimestamp()
+				updated = meta.NewTime(created.Add(time.Duration(resourceVersion) * time.Second))
+			}
+			applied.SetResourceVersion(strconv.Itoa(resourceVersion))
+			applied.SetCreationTimestamp(created)
+			applied.SetManagedFields([]meta.ManagedFieldsEntry{{Manager: r.URL.Query().Get("fieldManager"), Operation: meta.ManagedFieldsOperationApply, Time: &updated}})
+			s.stored = applied
+		}
+	default:
+		writeStatus(apierrors.NewMethodNotSupported(resource, r.Method))
+		return
+	}
+	data, _ := s.stored.MarshalJSON()
+	w.Write(data)
+}
+
+func TestApply(t *testing.T) {
+	server := &fakeApplyServer{}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	client, err := dynamic.NewForConfig(&rest.Config{Host: httpServer.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := events.NewInMemoryRecorder("apply")
+	syncCtx := controllerContext{controllerName: "ApplyController", eventRecorder: recorder, applyClient: client}
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	configMap := func(value string) *unstructured.Unstructured {
+		obj := makeUnstructuredConfigMap("test", "test-config", nil)
+		obj.Object["data"] = map[string]interface{}{"key": value}
+		return obj
+	}
+	reasons := func() []string {
+		result := []string{}
+		for _, e := range recorder.Events() {
+			result = append(result, e.Reason)
+		}
+		return result
+	}
+
+	for _, step := range []struct {
+		name            string
+		value           string
+		force           bool
+		conflicting     bool
+		expectChanged   bool
+		expectConflict  bool
+		expectedReasons []string
+	}{
+		{name: "create", value: "a", expectChanged: true, expectedReasons: []string{"ConfigMapCreated"}},
+		{name: "no-op", value: "a", expectedReasons: []string{"ConfigMapCreated"}},
+		{name: "update", value: "b", expectChanged: true, expectedReasons: []string{"ConfigMapCreated", "ConfigMapUpdated"}},
+		{name: "conflict", value: "c", conflicting: true, expectConflict: true, expectedReasons: []string{"ConfigMapCreated", "ConfigMapUpdated"}},
+		{name: "force", value: "c", conflicting: true, force: true, expectChanged: true, expectedReasons: []string{"ConfigMapCreated", "ConfigMapUpdated", "ConfigMapUpdated"}},
+	} {
+		server.lock.Lock()
+		server.conflicting = step.conflicting
+		server.lock.Unlock()
+
+		result, changed, err := syncCtx.Apply(configMaps, configMap(step.value), ApplyOptions{Force: step.force})
+		if step.expectConflict {
+			if !apierrors.IsConflict(err) {
+				t.Errorf("%s: expected conflict error, got %v", step.name, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %v", step.name, err)
+		} else if result.Object["data"].(map[string]interface{})["key"] != step.value {
+			t.Errorf("%s: expected applied object to be returned, got %#v", step.name, result.Object)
+		}
+		if changed != step.expectChanged {
+			t.Errorf("%s: expected changed=%t, got %t", step.name, step.expectChanged, changed)
+		}
+		if got := reasons(); !reflect.DeepEqual(got, step.expectedReasons) {
+			t.Errorf("%s: expected events %v, got %v", step.name, step.expectedReasons, got)
+		}
+	}
+
+	for _, fieldManager := range server.fieldManagers {
+		if fieldManager != "ApplyController" {
+			t.Errorf("expected controller name to be the field manager, got %q", fieldManager)
+		}
+	}
+	for _, method := range server.methods {
+		if method != http.MethodPatch {
+			t.Errorf("expected only the apply patch to be sent, got %s", method)
+		}
+	}
+	// the conflicting apply fails already in the dry run and is not sent
+	if server.dryRuns != 4 || len(server.methods) != 9 {
+		t.Errorf("expected every apply to be preceded by dry run, got %d dry runs of %d patches", server.dryRuns, len(server.methods))
+	}
+
+	if _, _, err := (controllerContext{}).Apply(configMaps, configMap("a"), ApplyOptions{}); err != ErrApplyClientNotSet {
+		t.Errorf("expected ErrApplyClientNotSet, got %v", err)
+	}
+}
+
+func TestApplyRecordsWriteOfSyncedObject(t *testing.T) {
+	httpServer := httptest.NewServer(&fakeApplyServer{})
+	defer httpServer.Close()
+	client, err := dynamic.NewForConfig(&rest.Config{Host: httpServer.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	objectMeta := meta.ObjectMeta{Namespace: "test", Name: "test-config"}
+
+	for _, test := range []struct {
+		name          string
+		queueObject   runtime.Object
+		expectWritten bool
+	}{
+		{name: "synced object", queueObject: &v1.ConfigMap{ObjectMeta: objectMeta}, expectWritten: true},
+		{name: "other kind with the same name", queueObject: &appsv1.Deployment{ObjectMeta: objectMeta}},
+	} {
+		syncCtx := controllerContext{
+			controllerName: "ApplyController",
+			eventRecorder:  events.NewInMemoryRecorder("apply"),
+			applyClient:    client,
+			writes:         newWriteTracker(time.Minute),
+			queueObject:    test.queueObject,
+		}
+		if _, _, err := syncCtx.Apply(configMaps, makeUnstructuredConfigMap("test", "test-config", nil), ApplyOptions{}); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if _, stale := syncCtx.writes.stale(test.queueObject); stale != test.expectWritten {
+			t.Errorf("%s: expected the synced object to be stale=%t, got %t", test.name, test.expectWritten, stale)
+		}
+		if len(syncCtx.writes.writes) > 1 {
+			t.Errorf("%s: expected at most the synced object write to be recorded, got %d writes", test.name, len(syncCtx.writes.writes))
+		}
+	}
+}
+
+func TestApplyDetectsChangeOfObjectAppliedByOthers(t *testing.T) {
+	server := &fakeApplyServer{}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	client, err := dynamic.NewForConfig(&rest.Config{Host: httpServer.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	required := func(value string) *unstructured.Unstructured {
+		obj := makeUnstructuredConfigMap("test", "test-config", nil)
+		obj.Object["data"] = map[string]interface{}{"key": value}
+		return obj
+	}
+	// the object was applied by other manager or by the controller before it was restarted
+	if _, _, err := (controllerContext{controllerName: "other", eventRecorder: events.NewInMemoryRecorder("other"), applyClient: client}).Apply(configMaps, required("a"), ApplyOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name           string
+		value          string
+		expectChanged  bool
+		expectedReason string
+	}{
+		{name: "no-op", value: "a"},
+		{name: "update", value: "b", expectChanged: true, expectedReason: "ConfigMapUpdated"},
+	} {
+		recorder := events.NewInMemoryRecorder("apply")
+		syncCtx := controllerContext{controllerName: "ApplyController", eventRecorder: recorder, applyClient: client}
+		_, changed, err := syncCtx.Apply(configMaps, required(test.value), ApplyOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if changed != test.expectChanged {
+			t.Errorf("%s: expected changed=%t, got %t", test.name, test.expectChanged, changed)
+		}
+		var reasons []string
+		for _, e := range recorder.Events() {
+			reasons = append(reasons, e.Reason)
+		}
+		if (len(test.expectedReason) == 0 && len(reasons) > 0) || (len(test.expectedReason) > 0 && !reflect.DeepEqual(reasons, []string{test.expectedReason})) {
+			t.Errorf("%s: expected event %q, got %v", test.name, test.expectedReason, reasons)
+		}
+	}
+}
@@ -2,89 +2,392 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"runtime/debug"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
-	"k8s.io/client-go/tools/cache"
-	"k8s.io/klog"
+	"k8s.io/client-go/util/workqueue"
 )
 
 // baseController represents generic Kubernetes controller boiler-plate
 type baseController struct {
-	cachesToSync    []cache.InformerSynced
-	sync            func(ctx context.Context, controllerContext Context) error
-	resyncEvery     time.Duration
-	ctx             controllerContext
-	shutdownContext context.Context
+	informers         []namedInformer
+	informerFactories []InformerStarter
+	informersCtx      context.Context
+	cacheSyncTimeout  time.Duration
+	sync              func(ctx context.Context, controllerContext Context) error
+	resyncEvery       time.Duration
+	ctx               controllerContext
+	handlers          *eventHandlerRegistration
+
+	// shutdownContext is cancelled when the controller shutdown is complete.
+	shutdownContext  context.Context
+	shutdownComplete context.CancelFunc
+
+	// logger is pre-populated with the controller name.
+	logger Logger
+	// syncCounter is used to generate unique sync id for every Sync() call.
+	syncCounter uint64
+	// tracer is used to create span for every Sync() call.
+	tracer Tracer
+	// recoverPanics turns panics in Sync() into errors.
+	recoverPanics bool
+	metrics       controllerMetrics
+	// syncTracker tracks worker states and sync errors for debugging.
+	syncTracker *syncTracker
+	// syncEvents records sync failures as events, nil when disabled.
+	syncEvents *syncErrorEvents
+	// newQueue creates a fresh queue when the controller is restarted.
+	newQueue func() workqueue.RateLimitingInterface
+
+	// lock guards the controller lifecycle fields below.
+	lock    sync.Mutex
+	running bool
+	// runs counts how many times the controller was started.
+	runs          int
+	capturePanics bool
+	stop          context.CancelFunc
+	// err is the reason the controller stopped.
+	err error
+}
+
+// SyncPanicError is returned when the Sync() function panicked and the controller was configured to recover from panics.
+type SyncPanicError struct {
+	// Value is the value passed to panic().
+	Value interface{}
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack []byte
+}
+
+func (e *SyncPanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", e.Value, e.Stack)
 }
 
 var _ Controller = &baseController{}
 
+var (
+	// ErrAlreadyStarted is returned when the controller is started while it is still running.
+	ErrAlreadyStarted = errors.New("controller is already started")
+	// ErrLeaderLost can be passed to Stop() when the controller is stopped because the leader election was lost.
+	ErrLeaderLost = errors.New("leader election lost")
+)
+
 func (c *baseController) Run(ctx context.Context, workers int) {
-	shutdownContext, shutdownComplete := context.WithCancel(context.Background())
-	if c.shutdownContext != nil {
-		panic(fmt.Sprintf("controller %q is already running", c.ctx.ControllerName()))
+	if err := c.markStarted(false); err != nil {
+		panic(fmt.Sprintf("controller %q cannot be started: %v", c.ctx.ControllerName(), err))
+	}
+	c.run(ctx, workers, nil)
+}
+
+func (c *baseController) Start(ctx context.Context, workers int) error {
+	if err := c.markStarted(true); err != nil {
+		return fmt.Errorf("%s: %w", c.ctx.ControllerName(), err)
+	}
+	started := make(chan error, 1)
+	go c.run(ctx, workers, started)
+	return <-started
+}
+
+func (c *baseController) Wait() error {
+	<-c.ShutdownContext().Done()
+	return c.Err()
+}
+
+func (c *baseController) Stop(reason error) {
+	c.lock.Lock()
+	stop := c.stop
+	if stop != nil && c.err == nil {
+		c.err = reason
+	}
+	c.lock.Unlock()
+	if stop != nil {
+		stop()
+	}
+}
+
+// markStarted marks the controller as running. When capturePanics is true, panics in workers stop the controller
+// and are reported by Wait() instead of crashing the process.
+// When the controller ran before, it is prepared for the restart with a fresh queue and shutdown context.
+func (c *baseController) markStarted(capturePanics bool) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.running {
+		return ErrAlreadyStarted
+	}
+	if c.runs > 0 {
+		c.shutdownContext, c.shutdownComplete = context.WithCancel(context.Background())
+		c.ctx.queue.set(c.newQueue())
+		c.err = nil
 	}
-	c.shutdownContext = shutdownContext
+	c.running = true
+	c.runs++
+	c.capturePanics = capturePanics
+	return nil
+}
+
+// markStopped marks the controller as not running, so it can be started again, and completes the shutdown.
+func (c *baseController) markStopped(shutdownComplete context.CancelFunc) {
+	c.lock.Lock()
+	c.running = false
+	c.stop = nil
+	c.lock.Unlock()
+	shutdownComplete()
+}
 
-	defer shutdownComplete()
+// run runs the controller and blocks until it is shut down.
+// The event handlers added to the informers feed the queue for the time the controller runs. The events delivered while
+// the controller was stopped are lost, so all objects in the informer caches are re-queued when the controller starts.
+// When the started channel is given, the result of the controller startup (cache sync) is sent to it.
+func (c *baseController) run(ctx context.Context, workers int, started chan<- error) {
+	ctx, stop := context.WithCancel(ctx)
+	c.lock.Lock()
+	c.stop = stop
+	shutdownComplete := c.shutdownComplete
+	c.lock.Unlock()
+	queue := c.ctx.Queue()
+
+	defer c.markStopped(shutdownComplete)
+	defer stop()
 	defer utilruntime.HandleCrash()
-	defer c.ctx.Queue().ShutDown()
-	defer klog.Infof("Shutting down %s ...", c.ctx.ControllerName())
+	defer queue.ShutDown()
+	defer c.logger.Info("Shutting down controller")
 
-	klog.Infof("Starting %s ...", c.ctx.ControllerName())
-	if !cache.WaitForCacheSync(ctx.Done(), c.cachesToSync...) {
+	c.logger.Info("Starting controller")
+	// the debounced events are dropped with the queue, the restarted controller re-queues the cached objects
+	if c.ctx.debouncer != nil {
+		defer c.ctx.debouncer.stop()
+	}
+	c.handlers.attach()
+	defer c.handlers.detach()
+	c.enqueueCachedObjects()
+	c.startInformers()
+	if err := c.waitForCacheSync(ctx); err != nil {
+		if ctx.Err() == nil {
+			c.logger.Error(err, "Failed to wait for caches to sync")
+			c.ctx.Events().Warningf("CacheSyncFailed", "Controller %s failed to start: %v", c.ctx.ControllerName(), err)
+			c.setErr(err)
+		}
+		if started != nil {
+			started <- err
+		}
 		return
 	}
-	klog.V(5).Infof("Caches synced for controller %s", c.ctx.ControllerName())
+	c.logger.V(5).Info("Caches synced")
 
 	var workerWaitGroup sync.WaitGroup
 
 	for i := 1; i <= workers; i++ {
-		klog.Infof("Starting #%d worker of %s controller ...", i, c.ctx.ControllerName())
+		workerID := i
+		workerLogger := c.logger.WithValues(LogKeyWorker, workerID)
+		workerLogger.Info("Starting worker")
 		workerWaitGroup.Add(1)
-		go wait.UntilWithContext(ctx, func(ctx context.Context) {
+		// the ctx can be already cancelled when the goroutine starts and the function passed to UntilWithContext
+		// is never called, so the wait group must be released outside of it.
+		go func() {
 			defer workerWaitGroup.Done()
-			defer klog.Infof("Shutting down worker of %s controller ...", c.ctx.ControllerName())
-			c.runWorker(ctx)
-		}, time.Second)
+			defer workerLogger.Info("Shutting down worker")
+			defer c.syncTracker.workerStopped(workerID)
+			wait.UntilWithContext(ctx, func(ctx context.Context) {
+				defer c.recoverWorkerPanic()
+				c.runWorker(ctx, workerID)
+			}, time.Second)
+		}()
 	}
 
 	// if periodical resync is requested, run it.
 	go c.runPeriodicalResync(ctx, c.resyncEvery)
 
+	if started != nil {
+		started <- nil
+	}
+
 	// wait for controller shutdown to be requested
 	<-ctx.Done()
 
+	// stop receiving events and unblock the workers waiting for queue items
+	c.handlers.detach()
+	queue.ShutDown()
+
 	// wait for all workers to finish their jobs
 	workerWaitGroup.Wait()
 }
 
+// recoverWorkerPanic stops the controller when the worker panics and the controller was started via Start().
+// The panic is then reported by Wait(). Otherwise the panic is propagated and crash the process.
+func (c *baseController) recoverWorkerPanic() {
+	c.lock.Lock()
+	capturePanics := c.capturePanics
+	c.lock.Unlock()
+	if !capturePanics {
+		return
+	}
+	if r := recover(); r != nil {
+		err := &SyncPanicError{Value: r, Stack: debug.Stack()}
+		c.logger.Error(err, "Worker panicked, stopping controller")
+		c.Stop(err)
+	}
+}
+
+// registerEventHandlers adds the event handler that feeds the controller queue to all informers. It is called once when
+// the controller is made, the handlers are attached to the controller only while it runs.
+func (c *baseController) registerEventHandlers() {
+	c.handlers = newEventHandlerRegistration(&c.ctx)
+	for i := range c.informers {
+		if c.informers[i].child {
+			c.informers[i].informer.AddEventHandler(c.handlers.childEventHandler())
+			continue
+		}
+		c.informers[i].informer.AddEventHandler(c.handlers.eventHandler(c.informers[i].cluster))
+	}
+}
+
+// enqueueCachedObjects queues all objects in the caches of the informers that feed the queue, as the events delivered
+// to the handlers before they were attached were ignored.
+func (c *baseController) enqueueCachedObjects() {
+	for i := range c.informers {
+		if c.informers[i].child {
+			continue
+		}
+		for _, obj := range c.informers[i].informer.GetStore().List() {
+			if runtimeObj, ok := obj.(runtime.Object); ok {
+				c.handlers.enqueue(withCluster(runtimeObj, c.informers[i].cluster), SyncEventAdd, true)
+			}
+		}
+	}
+}
+
 func (c *baseController) ShutdownContext() context.Context {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	return c.shutdownContext
 }
 
+func (c *baseController) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+func (c *baseController) setErr(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.err = err
+}
+
+func (c *baseController) QuarantinedItems() []QuarantinedItem {
+	return c.ctx.quarantine.list()
+}
+
+func (c *baseController) DebugInfo() DebugInfo {
+	info := c.syncTracker.debugInfo()
+	info.Name = c.ctx.ControllerName()
+	info.QueueDepth = c.ctx.Queue().Len()
+	for i := range c.informers {
+		info.Informers = append(info.Informers, InformerDebugInfo{
+			Name:   c.informers[i].name,
+			Synced: c.informers[i].informer.HasSynced(),
+		})
+	}
+	for _, item := range c.ctx.quarantine.list() {
+		info.Quarantined = append(info.Quarantined, kindKey(item.Kind, item.Key))
+	}
+	return info
+}
+
+func (c *baseController) ReleaseQuarantined(kind, key string) bool {
+	obj := c.ctx.quarantine.release(kind, key)
+	if obj == nil {
+		return false
+	}
+	c.logger.Info("Releasing quarantined object", LogKeyKind, kind, LogKeyQueueKey, key)
+	c.ctx.Queue().Add(obj)
+	return true
+}
+
 func (c *baseController) runPeriodicalResync(ctx context.Context, interval time.Duration) {
 	if interval == 0 {
 		return
 	}
 	go wait.UntilWithContext(ctx, func(ctx context.Context) {
-		if err := c.sync(ctx, c.ctx.withQueueObject(nil)); err != nil {
-			utilruntime.HandleError(fmt.Errorf("periodical resync of controller %s failed: %v", c.ctx.ControllerName(), err))
+		syncCtx := c.ctx.withQueueObject(nil).withLogger(c.syncLogger(0, nil))
+		if err := c.tracedSync(ctx, syncCtx, SyncEventResync, 0); err != nil {
+			syncCtx.Logger().Error(err, "Periodical resync failed")
 		}
 	}, interval)
 }
 
-func (c *baseController) runWorker(ctx context.Context) {
-	for c.processNextWorkItem(ctx) {
+// syncLogger returns logger populated with fields that identify single Sync() call.
+// The periodical resync use worker id 0 and has no queue key or kind.
+func (c *baseController) syncLogger(workerID int, obj runtime.Object) Logger {
+	syncID := strconv.FormatUint(atomic.AddUint64(&c.syncCounter, 1), 10)
+	logger := c.logger.WithValues(
+		LogKeyWorker, workerID,
+		LogKeyQueueKey, queueKeyFor(obj),
+		LogKeyKind, objectKind(obj),
+		LogKeySyncID, syncID,
+	)
+	if _, cluster := unwrapClusterObject(obj); len(cluster) > 0 {
+		logger = logger.WithValues(LogKeyCluster, cluster)
+	}
+	return logger
+}
+
+// tracedSync runs the sync function wrapped in a trace span.
+// The span is propagated via ctx passed to sync function, so the calls made inside can create child spans.
+func (c *baseController) tracedSync(ctx context.Context, syncCtx controllerContext, eventType string, retryCount int) error {
+	spanCtx, span := c.tracer.Start(ctx, "Sync",
+		Attribute{Key: TraceKeyController, Value: c.ctx.ControllerName()},
+		Attribute{Key: TraceKeyQueueKey, Value: queueKeyFor(syncCtx.queueObject)},
+		Attribute{Key: TraceKeyEventType, Value: eventType},
+		Attribute{Key: TraceKeyRetryCount, Value: retryCount},
+	)
+	defer span.End()
+
+	err := c.recoveredSync(spanCtx, syncCtx)
+	if err != nil {
+		c.metrics.syncFailures.Inc()
+		span.RecordError(err)
+		span.SetAttributes(Attribute{Key: TraceKeyOutcome, Value: SyncOutcomeError})
+	} else {
+		span.SetAttributes(Attribute{Key: TraceKeyOutcome, Value: SyncOutcomeSuccess})
 	}
+	return err
 }
 
-func (c *baseController) processNextWorkItem(ctx context.Context) bool {
+// recoveredSync runs the sync function and when configured, it turns a panic into SyncPanicError.
+// The panic is reported as warning event and counted by the panics metric.
+func (c *baseController) recoveredSync(ctx context.Context, syncCtx controllerContext) (err error) {
+	if !c.recoverPanics {
+		return c.sync(ctx, syncCtx)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = &SyncPanicError{Value: r, Stack: debug.Stack()}
+			c.metrics.syncPanics.Inc()
+			if key := queueKeyFor(syncCtx.queueObject); len(key) > 0 {
+				syncCtx.Events().Warningf("SyncPanic", "Sync of %s %q panicked: %v", objectKind(syncCtx.queueObject), key, r)
+			} else {
+				syncCtx.Events().Warningf("SyncPanic", "Sync panicked: %v", r)
+			}
+		}
+	}()
+	return c.sync(ctx, syncCtx)
+}
+
+func (c *baseController) runWorker(ctx context.Context, workerID int) {
+	for c.processNextWorkItem(ctx, workerID) {
+	}
+}
+
+func (c *baseController) processNextWorkItem(ctx context.Context, workerID int) bool {
+	c.syncTracker.workerIdle(workerID)
 	syncObject, quit := c.ctx.Queue().Get()
 	if quit || ctx.Err() != nil {
 		return false
@@ -99,10 +402,50 @@ func (c *baseController) processNextWorkItem(ctx context.Context) bool {
 
 	defer c.ctx.Queue().Done(runtimeObj)
 
-	if err := c.sync(ctx, c.ctx.withQueueObject(runtimeObj)); err != nil {
-		utilruntime.HandleError(fmt.Errorf("%s controller failed to sync %+v with: %w", c.ctx.ControllerName(), syncObject, err))
+	// the object was quarantined while it waited in the queue
+	if c.ctx.quarantine.isQuarantined(runtimeObj) {
+		c.ctx.Queue().Forget(runtimeObj)
+		return true
+	}
+
+	// the controller wrote newer version of the object, wait for the informer to observe it
+	if delay, stale := c.ctx.writes.stale(runtimeObj); stale {
+		c.logger.V(4).Info("Skipping sync of stale object", LogKeyQueueKey, queueKeyFor(runtimeObj), "recheckAfter", delay)
+		c.ctx.Queue().Forget(runtimeObj)
+		if delay > 0 {
+			c.ctx.Queue().AddAfter(runtimeObj, delay)
+		}
+		return true
+	}
+
+	// the children created or deleted by the previous sync were not observed yet
+	if delay, unsatisfied := c.ctx.expectations.unsatisfied(runtimeObj); unsatisfied {
+		c.logger.V(4).Info("Skipping sync of object with unsatisfied expectations", LogKeyQueueKey, queueKeyFor(runtimeObj), "recheckAfter", delay)
+		c.ctx.Queue().Forget(runtimeObj)
+		c.ctx.Queue().AddAfter(runtimeObj, delay)
+		return true
+	}
+
+	syncCtx := c.ctx.withQueueObject(runtimeObj).withLogger(c.syncLogger(workerID, runtimeObj))
+	eventType := c.ctx.eventTypes.pop(runtimeObj)
+	c.syncTracker.syncStarted(workerID, runtimeObj)
+	err := c.tracedSync(ctx, syncCtx, eventType, c.ctx.Queue().NumRequeues(runtimeObj))
+	c.syncTracker.syncFinished(workerID)
+	if err != nil {
+		failures, quarantined := c.ctx.quarantine.failed(runtimeObj, err)
+		c.syncTracker.syncFailed(runtimeObj, err, failures)
+		c.syncEvents.failed(runtimeObj, err)
+		if quarantined {
+			syncCtx.Logger().Error(err, "Sync failed too many times, object quarantined", "failures", failures)
+			c.ctx.Queue().Forget(runtimeObj)
+			return true
+		}
+		syncCtx.Logger().Error(err, "Sync failed")
 		c.ctx.Queue().AddRateLimited(runtimeObj)
 	} else {
+		c.ctx.quarantine.succeeded(runtimeObj)
+		c.syncTracker.syncSucceeded(runtimeObj)
+		c.syncEvents.succeeded(runtimeObj)
 		c.ctx.Queue().Forget(runtimeObj)
 	}
 
@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// clusterObject is a queue item for objects observed by informers tagged with cluster name.
+// The same object can exist in multiple clusters, so the cluster name is part of its queue key.
+type clusterObject struct {
+	runtime.Object
+	cluster string
+}
+
+// withCluster tags the object with the cluster name. Objects from informers without cluster are not tagged.
+func withCluster(obj runtime.Object, cluster string) runtime.Object {
+	if len(cluster) == 0 || obj == nil {
+		return obj
+	}
+	return clusterObject{Object: obj, cluster: cluster}
+}
+
+// unwrapClusterObject returns the original object and the name of the cluster it came from.
+func unwrapClusterObject(obj runtime.Object) (runtime.Object, string) {
+	if c, ok := obj.(clusterObject); ok {
+		return c.Object, c.cluster
+	}
+	return obj, ""
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func TestMultiClusterController(t *testing.T) {
+	clients := map[string]kubernetes.Interface{
+		"hub":   fake.NewSimpleClientset(makeFakeSecret()),
+		"spoke": fake.NewSimpleClientset(makeFakeSecret()),
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	factory := NewFactory()
+	for cluster, client := range clients {
+		kubeInformers := informers.NewSharedInformerFactoryWithOptions(client, 1*time.Minute, informers.WithNamespace("test"))
+		factory.ClusterInformers(cluster, kubeInformers.Core().V1().Secrets().Informer())
+		go kubeInformers.Start(ctx.Done())
+	}
+
+	controller := factory.Sync(func(ctx context.Context, controllerContext Context) error {
+		secret := controllerContext.GetQueueObject().(*v1.Secret)
+		if secret.Labels["synced-in"] == controllerContext.ClusterName() {
+			return nil
+		}
+		// fan out to the client of the cluster the object came from
+		secret.Labels = map[string]string{"synced-in": controllerContext.ClusterName()}
+		_, err := clients[controllerContext.ClusterName()].CoreV1().Secrets(secret.Namespace).Update(secret)
+		return err
+	}).Controller("MultiClusterController", events.NewInMemoryRecorder("multi-cluster-controller"))
+
+	go controller.Run(ctx, 1)
+
+	for cluster, client := range clients {
+		if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+			secret, err := client.CoreV1().Secrets("test").Get("test-secret", meta.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			return secret.Labels["synced-in"] == cluster, nil
+		}); err != nil {
+			t.Errorf("expected secret in cluster %q to be synced: %v", cluster, err)
+		}
+	}
+
+	debugInfo := controller.DebugInfo()
+	if len(debugInfo.Informers) != 2 {
+		t.Errorf("expected two informers, got %#v", debugInfo.Informers)
+	}
+}
+
+func TestClusterQueueKey(t *testing.T) {
+	secret := makeFakeSecret()
+	if key := queueKeyFor(withCluster(secret, "spoke")); key != "spoke/test/test-secret" {
+		t.Errorf("expected cluster to be part of queue key, got %q", key)
+	}
+	if key := queueKeyFor(withCluster(secret, "")); key != "test/test-secret" {
+		t.Errorf("expected untagged object to have namespace/name key, got %q", key)
+	}
+	if kind := objectKind(withCluster(secret, "spoke")); kind != "Secret" {
+		t.Errorf("expected Secret kind, got %q", kind)
+	}
+	syncCtx := controllerContext{}.withQueueObject(withCluster(secret, "spoke"))
+	if syncCtx.ClusterName() != "spoke" || syncCtx.GetObjectMeta().GetName() != "test-secret" {
+		t.Errorf("expected object from spoke cluster, got %q and %v", syncCtx.ClusterName(), syncCtx.GetObjectMeta())
+	}
+	if _, ok := syncCtx.GetQueueObject().(*v1.Secret); !ok {
+		t.Errorf("expected queue object to be unwrapped, got %T", syncCtx.GetQueueObject())
+	}
+}
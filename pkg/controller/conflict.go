@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// conflictRetry configures the retries of updates that failed on conflict within single Sync() call.
+type conflictRetry struct {
+	backoff wait.Backoff
+	retries CounterMetric
+}
+
+func newConflictRetry(backoff wait.Backoff, retries CounterMetric) *conflictRetry {
+	return &conflictRetry{backoff: backoff, retries: retries}
+}
+
+// RetryOnConflict gets the object and passes its deep copy to the update function. When the update fails with conflict,
+// the object is read again and the update is retried with backoff. Other errors and the conflict after the last retry
+// are returned as they are.
+func (c controllerContext) RetryOnConflict(get func() (runtime.Object, error), update func(obj runtime.Object) error) error {
+	r := c.conflictRetry
+	if r == nil {
+		r = newConflictRetry(retry.DefaultRetry, noopMetric{})
+	}
+	attempt := 0
+	return retry.RetryOnConflict(r.backoff, func() error {
+		if attempt > 0 {
+			r.retries.Inc()
+			if c.logger != nil {
+				c.logger.V(4).Info("Retrying update after conflict", "attempt", attempt)
+			}
+		}
+		attempt++
+		obj, err := get()
+		if err != nil {
+			return err
+		}
+		// the object can come from the informer cache, which must not be mutated
+		return update(obj.DeepCopyObject())
+	})
+}
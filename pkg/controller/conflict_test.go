@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func TestRetryOnConflict(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		conflicts         int64
+		expectedRetries   int64
+		expectedFailures  int64
+		expectLabelUpdate bool
+	}{
+		{name: "conflict resolved by retry", conflicts: 2, expectedRetries: 2, expectLabelUpdate: true},
+		{name: "retries exhausted", conflicts: 100, expectedRetries: 3, expectedFailures: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeClient := fake.NewSimpleClientset(makeFakeSecret())
+			var updates int64
+			kubeClient.PrependReactor("update", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				if atomic.AddInt64(&updates, 1) <= tc.conflicts {
+					return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "secrets"}, "test-secret", nil)
+				}
+				return false, nil, nil
+			})
+
+			kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+			go kubeInformers.Start(ctx.Done())
+
+			metrics := &fakeMetricsProvider{}
+			synced := make(chan error, 1)
+			secretLister := kubeInformers.Core().V1().Secrets().Lister()
+			controller := NewFactory().
+				Informers(kubeInformers.Core().V1().Secrets().Informer()).
+				WithMetricsProvider(metrics).
+				WithConflictBackoff(wait.Backoff{Steps: 4, Duration: time.Millisecond, Factor: 1}).
+				Sync(func(ctx context.Context, controllerContext Context) error {
+					err := controllerContext.RetryOnConflict(func() (runtime.Object, error) {
+						return secretLister.Secrets("test").Get("test-secret")
+					}, func(obj runtime.Object) error {
+						secret := obj.(*v1.Secret)
+						secret.Labels = map[string]string{"synced": "true"}
+						_, err := kubeClient.CoreV1().Secrets("test").Update(secret)
+						return err
+					})
+					select {
+					case synced <- err:
+					default:
+					}
+					return err
+				}).Controller("ConflictController", events.NewInMemoryRecorder("conflict-controller"))
+			go controller.Run(ctx, 1)
+
+			select {
+			case err := <-synced:
+				if tc.expectLabelUpdate && err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !tc.expectLabelUpdate && !apierrors.IsConflict(err) {
+					t.Fatalf("expected conflict error, got %v", err)
+				}
+			case <-time.After(30 * time.Second):
+				t.Fatal("test timeout")
+			}
+
+			if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+				return metrics.syncFailures.Value() >= tc.expectedFailures, nil
+			}); err != nil {
+				t.Fatalf("expected %d sync failures, got %d", tc.expectedFailures, metrics.syncFailures.Value())
+			}
+			if retries := metrics.conflictRetries.Value(); retries < tc.expectedRetries {
+				t.Errorf("expected %d conflict retries, got %d", tc.expectedRetries, retries)
+			}
+			if tc.expectLabelUpdate {
+				if failures := metrics.syncFailures.Value(); failures != 0 {
+					t.Errorf("expected conflicts to not be counted as sync failures, got %d", failures)
+				}
+				secret, _ := kubeClient.CoreV1().Secrets("test").Get("test-secret", meta.GetOptions{})
+				if secret.Labels["synced"] != "true" {
+					t.Errorf("expected secret to be updated, got %v", secret.Labels)
+				}
+			}
+		})
+	}
+}
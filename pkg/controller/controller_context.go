@@ -2,11 +2,14 @@ package controller
 
 import (
 	"fmt"
+	"reflect"
+	"sync"
 
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 
@@ -15,26 +18,121 @@ import (
 
 // ctx provide access to controller name, queue and event recorder.
 type controllerContext struct {
-	queue          workqueue.RateLimitingInterface
+	queue          *queueHolder
 	eventRecorder  events.Recorder
 	controllerName string
+	logger         Logger
 
 	// queueObject holds the object we got from informer
 	// There is no direct access to this object to prevent cache mutation.
 	queueObject runtime.Object
+
+	// eventTypes tracks the last informer event type observed for queue keys.
+	eventTypes *eventTypeTracker
+	// quarantine holds the objects that failed to sync too many times in a row.
+	quarantine *quarantine
+	// syncTracker records the recent sync errors, they are forgotten with the quarantine when the object is deleted.
+	syncTracker *syncTracker
+	// applyClient is used for server-side apply, nil when not configured.
+	applyClient dynamic.Interface
+	// conflictRetry configures RetryOnConflict().
+	conflictRetry *conflictRetry
+	// writes tracks the objects written by Sync() until the informer observes them.
+	writes *writeTracker
+	// expectations tracks the child objects created or deleted by Sync() until the child informers observe them.
+	expectations *expectations
+	// owners are the informers that hold the owners of the objects observed by the child informers.
+	owners []cache.SharedInformer
+	// indexers are the indexers of the controller informers by the informer name. The informers built for every
+	// namespace are also listed under their shared name.
+	indexers map[string][]cache.Indexer
+	// debouncer collapses the informer events for the same key, nil when disabled.
+	debouncer *debouncer
+}
+
+// queueHolder holds the controller queue. The queue is replaced with a new one every time the controller is restarted,
+// because the queue of the stopped controller is shut down and cannot be used anymore.
+type queueHolder struct {
+	queue workqueue.RateLimitingInterface
+	lock  sync.RWMutex
+}
+
+func newQueueHolder(queue workqueue.RateLimitingInterface) *queueHolder {
+	return &queueHolder{queue: queue}
+}
+
+func (h *queueHolder) get() workqueue.RateLimitingInterface {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.queue
+}
+
+func (h *queueHolder) set(queue workqueue.RateLimitingInterface) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.queue = queue
+}
+
+// Informer event types that caused the Sync() to run.
+const (
+	SyncEventAdd     = "add"
+	SyncEventUpdate  = "update"
+	SyncEventDelete  = "delete"
+	SyncEventResync  = "resync"
+	SyncEventRequeue = "requeue"
+	// SyncEventChild is used when the child object observed by the child informer changed, see Factory.ChildInformers().
+	SyncEventChild = "child"
+)
+
+// eventTypeTracker records the last informer event type per object.
+type eventTypeTracker struct {
+	eventTypes map[string]string
+	lock       sync.Mutex
+}
+
+func newEventTypeTracker() *eventTypeTracker {
+	return &eventTypeTracker{eventTypes: map[string]string{}}
+}
+
+func (t *eventTypeTracker) observe(obj runtime.Object, eventType string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.eventTypes[objectKeyFor(obj)] = eventType
+}
+
+// pop returns the last observed event type for the object and forget it.
+func (t *eventTypeTracker) pop(obj runtime.Object) string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key := objectKeyFor(obj)
+	eventType, ok := t.eventTypes[key]
+	if !ok {
+		// the object was added to the queue manually or re-queued after failure
+		return SyncEventRequeue
+	}
+	delete(t.eventTypes, key)
+	return eventType
 }
 
 var _ Context = controllerContext{}
 
 func (c controllerContext) GetQueueObject() runtime.Object {
-	if c.queueObject == nil {
+	obj, _ := unwrapClusterObject(c.queueObject)
+	if obj == nil {
 		return nil
 	}
-	return c.queueObject.DeepCopyObject()
+	return obj.DeepCopyObject()
+}
+
+// ClusterName returns the name of the cluster the object we observed change to came from.
+// If the object is not set or the informer was not tagged with cluster, it returns empty string.
+func (c controllerContext) ClusterName() string {
+	_, cluster := unwrapClusterObject(c.queueObject)
+	return cluster
 }
 
 func (c controllerContext) Queue() workqueue.RateLimitingInterface {
-	return c.queue
+	return c.queue.get()
 }
 
 func (c controllerContext) Events() events.Recorder {
@@ -45,13 +143,18 @@ func (c controllerContext) ControllerName() string {
 	return c.controllerName
 }
 
+func (c controllerContext) Logger() Logger {
+	return c.logger
+}
+
 // GetObjectMeta return metadata of object we observed change to via informer.
 // If the object is not set, it returns nil.
 func (c controllerContext) GetObjectMeta() metav1.Object {
-	if c.queueObject == nil {
+	obj, _ := unwrapClusterObject(c.queueObject)
+	if obj == nil {
 		return nil
 	}
-	metaObj, err := meta.Accessor(c.queueObject)
+	metaObj, err := meta.Accessor(obj)
 	if err != nil {
 		return nil
 	}
@@ -62,14 +165,69 @@ func (c controllerContext) GetObjectMeta() metav1.Object {
 func (c controllerContext) withQueueObject(obj runtime.Object) controllerContext {
 	return controllerContext{
 		controllerName: c.ControllerName(),
-		eventRecorder:  c.Events(),
-		queue:          c.Queue(),
+		eventRecorder:  eventsForObject(c.Events(), obj),
+		queue:          c.queue,
+		logger:         c.Logger(),
+		eventTypes:     c.eventTypes,
+		quarantine:     c.quarantine,
+		syncTracker:    c.syncTracker,
+		applyClient:    c.applyClient,
+		conflictRetry:  c.conflictRetry,
+		writes:         c.writes,
+		expectations:   c.expectations,
+		owners:         c.owners,
+		indexers:       c.indexers,
+		debouncer:      c.debouncer,
 		queueObject:    obj,
 	}
 }
 
-// getEventHandler provides default event handler that is added to an informers passed to controller factory.
-func (c *controllerContext) getEventHandler() cache.ResourceEventHandler {
+// withLogger makes a copy of original ctx and return new ctx that has the logger set.
+func (c controllerContext) withLogger(logger Logger) controllerContext {
+	c.logger = logger
+	return c
+}
+
+// eventHandlerRegistration connects the event handler added to the informers with the running controller.
+// The shared informers do not allow to remove event handlers and every added handler runs its own goroutines until the
+// informer stops, so the handler is added once when the controller is made and it is attached to the controller only
+// while the controller runs. The detached handler ignores all events.
+type eventHandlerRegistration struct {
+	ctx      *controllerContext
+	attached bool
+	lock     sync.RWMutex
+}
+
+func newEventHandlerRegistration(ctx *controllerContext) *eventHandlerRegistration {
+	return &eventHandlerRegistration{ctx: ctx}
+}
+
+// attach makes the event handler feed the controller queue.
+func (r *eventHandlerRegistration) attach() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.attached = true
+}
+
+// detach makes the event handler no-op.
+func (r *eventHandlerRegistration) detach() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.attached = false
+}
+
+func (r *eventHandlerRegistration) enqueue(obj runtime.Object, eventType string, realChange bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if !r.attached {
+		return
+	}
+	r.ctx.enqueue(obj, eventType, realChange)
+}
+
+// eventHandler provides default event handler that is added to an informers passed to controller factory.
+// The objects observed by the informer are tagged with the given cluster name, unless it is empty.
+func (r *eventHandlerRegistration) eventHandler(cluster string) cache.ResourceEventHandler {
 	return cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			runtimeObj, ok := obj.(runtime.Object)
@@ -77,7 +235,7 @@ func (c *controllerContext) getEventHandler() cache.ResourceEventHandler {
 				utilruntime.HandleError(fmt.Errorf("added object %+v is not runtime Object", obj))
 				return
 			}
-			c.queue.Add(runtimeObj)
+			r.enqueue(withCluster(runtimeObj, cluster), SyncEventAdd, true)
 		},
 		UpdateFunc: func(old, new interface{}) {
 			runtimeObj, ok := new.(runtime.Object)
@@ -85,20 +243,143 @@ func (c *controllerContext) getEventHandler() cache.ResourceEventHandler {
 				utilruntime.HandleError(fmt.Errorf("updated object %+v is not runtime Object", runtimeObj))
 				return
 			}
-			c.queue.Add(runtimeObj)
+			r.enqueue(withCluster(runtimeObj, cluster), SyncEventUpdate, isRealChange(old, new))
 		},
 		DeleteFunc: func(obj interface{}) {
 			runtimeObj, ok := obj.(runtime.Object)
 			if !ok {
 				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
 				if ok {
-					c.queue.Add(tombstone.Obj.(runtime.Object))
+					r.enqueue(withCluster(tombstone.Obj.(runtime.Object), cluster), SyncEventDelete, true)
 					return
 				}
 				utilruntime.HandleError(fmt.Errorf("updated object %+v is not runtime Object", runtimeObj))
 				return
 			}
-			c.queue.Add(runtimeObj)
+			r.enqueue(withCluster(runtimeObj, cluster), SyncEventDelete, true)
 		},
 	}
 }
+
+func (r *eventHandlerRegistration) childObserved(obj runtime.Object, eventType string) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if !r.attached {
+		return
+	}
+	r.ctx.childObserved(obj, eventType)
+}
+
+// childEventHandler provides event handler that is added to the child informers. The child objects are not queued,
+// their changes lower the expectations of the owner and re-queue the owner instead.
+func (r *eventHandlerRegistration) childEventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if runtimeObj, ok := obj.(runtime.Object); ok {
+				r.childObserved(runtimeObj, SyncEventAdd)
+			}
+		},
+		UpdateFunc: func(old, new interface{}) {
+			if runtimeObj, ok := new.(runtime.Object); ok && isRealChange(old, new) {
+				r.childObserved(runtimeObj, SyncEventUpdate)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if runtimeObj, ok := obj.(runtime.Object); ok {
+				r.childObserved(runtimeObj, SyncEventDelete)
+			}
+		},
+	}
+}
+
+// enqueue adds the object observed by informer to the queue. When debounce is configured, the object is added after the
+// debounce window. Quarantined objects are only released and re-queued when the informer observed a real change to them.
+func (c *controllerContext) enqueue(obj runtime.Object, eventType string, realChange bool) {
+	if c.quarantine.isQuarantined(obj) {
+		if !realChange {
+			return
+		}
+		c.quarantine.release(objectKind(obj), queueKeyFor(obj))
+	}
+	if eventType == SyncEventDelete {
+		c.quarantine.forget(obj)
+		c.syncTracker.forget(obj)
+	}
+	c.writes.observe(obj, eventType)
+	c.expectations.observe(obj, eventType)
+	c.eventTypes.observe(obj, eventType)
+	if c.debouncer != nil {
+		c.debouncer.add(obj, func(obj runtime.Object) {
+			c.Queue().Add(obj)
+		})
+		return
+	}
+	c.Queue().Add(obj)
+}
+
+// isRealChange returns false when the update event was caused by informer resync and the object did not change.
+func isRealChange(old, new interface{}) bool {
+	oldMeta, err := meta.Accessor(old)
+	if err != nil {
+		return true
+	}
+	newMeta, err := meta.Accessor(new)
+	if err != nil {
+		return true
+	}
+	return oldMeta.GetResourceVersion() != newMeta.GetResourceVersion()
+}
+
+// queueKeyFor returns the "namespace/name" key for the given object.
+// Objects tagged with cluster name have "cluster/namespace/name" key.
+// Periodical resyncs does not have any object, in that case empty string is returned.
+func queueKeyFor(obj runtime.Object) string {
+	obj, cluster := unwrapClusterObject(obj)
+	if obj == nil {
+		return ""
+	}
+	if len(cluster) > 0 {
+		return cluster + "/" + queueKeyFor(obj)
+	}
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return fmt.Sprintf("%v", obj)
+	}
+	return key
+}
+
+// objectKeyFor returns the key that identifies the object in the controller state kept per object, the kind and the
+// queue key, so objects of different kinds with the same namespace and name do not share the state.
+// Periodical resyncs does not have any object, in that case empty string is returned.
+func objectKeyFor(obj runtime.Object) string {
+	key := queueKeyFor(obj)
+	if len(key) == 0 {
+		return ""
+	}
+	return kindKey(objectKind(obj), key)
+}
+
+// kindKey returns the object key for the kind and "namespace/name" queue key.
+func kindKey(kind, key string) string {
+	return kind + "/" + key
+}
+
+// objectKind returns the kind of the given object, qualified with the group when the object has TypeMeta set.
+// Objects coming from informers usually have empty TypeMeta, so the Go type name is used as a fallback.
+func objectKind(obj runtime.Object) string {
+	obj, _ = unwrapClusterObject(obj)
+	if obj == nil {
+		return ""
+	}
+	if gvk := obj.GetObjectKind().GroupVersionKind(); len(gvk.Kind) > 0 {
+		return gvk.GroupKind().String()
+	}
+	t := reflect.TypeOf(obj)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
@@ -2,7 +2,10 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -11,6 +14,7 @@ import (
 	"k8s.io/client-go/informers"
 	v12 "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
 
 	"github.com/openshift/library-go/pkg/operator/events"
 )
@@ -162,3 +166,301 @@ func TestSimpleController(t *testing.T) {
 		t.Fatal("test timeout")
 	}
 }
+
+type fakeCounter struct {
+	count int64
+}
+
+func (c *fakeCounter) Inc() {
+	atomic.AddInt64(&c.count, 1)
+}
+
+func (c *fakeCounter) Value() int64 {
+	return atomic.LoadInt64(&c.count)
+}
+
+type fakeMetricsProvider struct {
+	syncPanics      fakeCounter
+	syncFailures    fakeCounter
+	conflictRetries fakeCounter
+}
+
+func (p *fakeMetricsProvider) NewSyncPanicsMetric(string) CounterMetric {
+	return &p.syncPanics
+}
+
+func (p *fakeMetricsProvider) NewSyncFailuresMetric(string) CounterMetric {
+	return &p.syncFailures
+}
+
+func (p *fakeMetricsProvider) NewConflictRetriesMetric(string) CounterMetric {
+	return &p.conflictRetries
+}
+
+func TestSyncPanicRecovery(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(makeFakeSecret())
+
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	go kubeInformers.Start(ctx.Done())
+
+	metrics := &fakeMetricsProvider{}
+	recorder := events.NewInMemoryRecorder("panic-controller")
+	controllerSynced := make(chan struct{})
+	syncCount := 0
+	controller := NewFactory().Informers(kubeInformers.Core().V1().Secrets().Informer()).WithPanicRecovery().WithMetricsProvider(metrics).Sync(func(ctx context.Context, controllerContext Context) error {
+		syncCount++
+		if syncCount == 1 {
+			panic("bad object")
+		}
+		close(controllerSynced)
+		return nil
+	}).Controller("PanicController", recorder)
+
+	go controller.Run(ctx, 1)
+
+	select {
+	case <-controllerSynced:
+	case <-time.After(30 * time.Second):
+		t.Fatal("test timeout")
+	}
+
+	if metrics.syncPanics.Value() != 1 {
+		t.Errorf("expected 1 panic to be counted, got %d", metrics.syncPanics.Value())
+	}
+	warnings := 0
+	for _, e := range recorder.Events() {
+		if e.Reason == "SyncPanic" && strings.Contains(e.Message, `"test/test-secret" panicked: bad object`) {
+			warnings++
+		}
+	}
+	if warnings != 1 {
+		t.Errorf("expected 1 SyncPanic warning event, got %#v", recorder.Events())
+	}
+}
+
+func TestStartAndWait(t *testing.T) {
+	newController := func(syncFn SyncFunc) Controller {
+		kubeClient := fake.NewSimpleClientset(makeFakeSecret())
+		kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+		return NewFactory().WithInformerFactories(kubeInformers).Informers(kubeInformers.Core().V1().Secrets().Informer()).
+			Sync(syncFn).Controller("StartController", events.NewInMemoryRecorder("start-controller"))
+	}
+	waitFor := func(controller Controller) error {
+		select {
+		case <-controller.ShutdownContext().Done():
+			return controller.Wait()
+		case <-time.After(30 * time.Second):
+			t.Fatal("test timeout")
+		}
+		return nil
+	}
+
+	t.Run("clean stop", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		defer cancel()
+		synced := make(chan struct{})
+		var once sync.Once
+		controller := newController(func(ctx context.Context, controllerContext Context) error {
+			once.Do(func() { close(synced) })
+			return nil
+		})
+		if controller.ShutdownContext() == nil {
+			t.Fatal("expected shutdown context to be valid before start")
+		}
+		if err := controller.Start(ctx, 1); err != nil {
+			t.Fatalf("unexpected start error: %v", err)
+		}
+		if err := controller.Start(ctx, 1); !errors.Is(err, ErrAlreadyStarted) {
+			t.Fatalf("expected second start to fail with ErrAlreadyStarted, got %v", err)
+		}
+		<-synced
+		cancel()
+		if err := waitFor(controller); err != nil {
+			t.Fatalf("expected clean stop, got %v", err)
+		}
+	})
+
+	t.Run("leader lost", func(t *testing.T) {
+		controller := newController(func(ctx context.Context, controllerContext Context) error { return nil })
+		if err := controller.Start(context.TODO(), 1); err != nil {
+			t.Fatalf("unexpected start error: %v", err)
+		}
+		controller.Stop(ErrLeaderLost)
+		if err := waitFor(controller); err != ErrLeaderLost {
+			t.Fatalf("expected ErrLeaderLost, got %v", err)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		controller := newController(func(ctx context.Context, controllerContext Context) error { panic("bad object") })
+		if err := controller.Start(context.TODO(), 1); err != nil {
+			t.Fatalf("unexpected start error: %v", err)
+		}
+		var panicErr *SyncPanicError
+		if err := waitFor(controller); !errors.As(err, &panicErr) || panicErr.Value != "bad object" {
+			t.Fatalf("expected SyncPanicError, got %v", err)
+		}
+	})
+}
+
+// countingInformer counts the event handlers added to the informer.
+type countingInformer struct {
+	cache.SharedIndexInformer
+	handlers int32
+}
+
+func (i *countingInformer) AddEventHandler(handler cache.ResourceEventHandler) {
+	atomic.AddInt32(&i.handlers, 1)
+	i.SharedIndexInformer.AddEventHandler(handler)
+}
+
+func TestRestart(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(makeFakeSecret())
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+	informersCtx, cancelInformers := context.WithCancel(context.TODO())
+	defer cancelInformers()
+
+	// the informers outlive the controller
+	go kubeInformers.Start(informersCtx.Done())
+
+	synced := make(chan string, 10)
+	informer := &countingInformer{SharedIndexInformer: kubeInformers.Core().V1().Secrets().Informer()}
+	controller := NewFactory().Informers(informer).Sync(func(ctx context.Context, controllerContext Context) error {
+		synced <- controllerContext.GetObjectMeta().GetName()
+		return nil
+	}).Controller("RestartController", events.NewInMemoryRecorder("restart-controller"))
+
+	waitForSync := func() {
+		select {
+		case name := <-synced:
+			if name != "test-secret" {
+				t.Fatalf("expected test-secret to be synced, got %q", name)
+			}
+		case <-time.After(30 * time.Second):
+			t.Fatal("test timeout")
+		}
+	}
+
+	if err := controller.Start(context.TODO(), 1); err != nil {
+		t.Fatalf("unexpected start error: %v", err)
+	}
+	waitForSync()
+	controller.Stop(ErrLeaderLost)
+	if err := controller.Wait(); err != ErrLeaderLost {
+		t.Fatalf("expected ErrLeaderLost, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	if err := controller.Start(ctx, 1); err != nil {
+		t.Fatalf("unexpected restart error: %v", err)
+	}
+	if controller.Err() != nil {
+		t.Errorf("expected error to be reset on restart, got %v", controller.Err())
+	}
+	// the restarted controller re-queues the current informer state
+	waitForSync()
+	if handlers := atomic.LoadInt32(&informer.handlers); handlers != 1 {
+		t.Errorf("expected the event handler to be added once, got %d handlers", handlers)
+	}
+	cancel()
+	if err := controller.Wait(); err != nil {
+		t.Fatalf("expected clean stop, got %v", err)
+	}
+}
+
+func TestRestartWithInformerFactories(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(makeFakeSecret())
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+	informersCtx, cancelInformers := context.WithCancel(context.TODO())
+	defer cancelInformers()
+	synced := make(chan struct{}, 10)
+	controller := NewFactory().WithInformerFactories(kubeInformers).WithInformersContext(informersCtx).
+		Informers(kubeInformers.Core().V1().Secrets().Informer()).
+		Sync(func(ctx context.Context, controllerContext Context) error {
+			synced <- struct{}{}
+			return nil
+		}).
+		Controller("RestartController", events.NewInMemoryRecorder("restart-controller"))
+	waitForSync := func() {
+		select {
+		case <-synced:
+		case <-time.After(10 * time.Second):
+			t.Fatal("test timeout")
+		}
+	}
+
+	if err := controller.Start(context.TODO(), 1); err != nil {
+		t.Fatalf("unexpected start error: %v", err)
+	}
+	waitForSync()
+	controller.Stop(ErrLeaderLost)
+	controller.Wait()
+
+	// the informers keep running, so the restarted controller re-queues their state
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	if err := controller.Start(ctx, 1); err != nil {
+		t.Fatalf("unexpected restart error: %v", err)
+	}
+	waitForSync()
+}
+
+func TestEventHandlersDetachedOnShutdown(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(makeFakeSecret())
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+	informersCtx, cancelInformers := context.WithCancel(context.TODO())
+	defer cancelInformers()
+	go kubeInformers.Start(informersCtx.Done())
+
+	secretInformer := kubeInformers.Core().V1().Secrets().Informer()
+	newController := func(name string, synced chan<- string) Controller {
+		return NewFactory().Informers(secretInformer).Sync(func(ctx context.Context, controllerContext Context) error {
+			synced <- controllerContext.GetObjectMeta().GetName()
+			return nil
+		}).Controller(name, events.NewInMemoryRecorder(name))
+	}
+
+	stoppedSynced := make(chan string, 10)
+	stopped := newController("StoppedController", stoppedSynced).(*baseController)
+	if err := stopped.Start(context.TODO(), 1); err != nil {
+		t.Fatalf("unexpected start error: %v", err)
+	}
+	<-stoppedSynced
+	stopped.Stop(nil)
+	stopped.Wait()
+
+	// the running controller shares the informer and tells when the new secret event was delivered
+	runningSynced := make(chan string, 10)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go newController("RunningController", runningSynced).Run(ctx, 1)
+
+	secret := makeFakeSecret()
+	secret.Name = "new-secret"
+	if _, err := kubeClient.CoreV1().Secrets("test").Create(secret); err != nil {
+		t.Fatalf("failed to create fake secret: %v", err)
+	}
+	for name := ""; name != "new-secret"; {
+		select {
+		case name = <-runningSynced:
+		case <-time.After(30 * time.Second):
+			t.Fatal("test timeout")
+		}
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if eventType := stopped.ctx.eventTypes.pop(secret); eventType != SyncEventRequeue {
+		t.Errorf("expected no event to be observed by stopped controller, got %q", eventType)
+	}
+	if depth := stopped.ctx.Queue().Len(); depth != 0 {
+		t.Errorf("expected no items queued in stopped controller, got %d", depth)
+	}
+	select {
+	case name := <-stoppedSynced:
+		t.Errorf("unexpected sync of %q in stopped controller", name)
+	default:
+	}
+}
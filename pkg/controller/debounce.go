@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// debouncedKey is the object waiting for the debounce window to pass.
+type debouncedKey struct {
+	// obj is the latest object observed for the key, it is queued when the window passes.
+	obj runtime.Object
+	// deadline is when the key is queued, it is moved with every event up to the max delay after the first event.
+	deadline time.Time
+	// maxDeadline is the first event time plus the max delay.
+	maxDeadline time.Time
+	// stopTimer cancels the scheduled fire of the key.
+	stopTimer func() bool
+}
+
+// debouncer collapses the informer events for the same object into single sync. Every event moves the sync of the
+// key by the window, so a burst of events is synced once after it settles. To not starve the key under sustained
+// churn, the sync is never delayed more than the max delay after the first event.
+type debouncer struct {
+	window   time.Duration
+	maxDelay time.Duration
+	now      func() time.Time
+	// afterFunc schedules the function to run after the duration and returns the function that cancels it, replaced in
+	// tests.
+	afterFunc func(d time.Duration, f func()) func() bool
+	pending   map[string]*debouncedKey
+	lock      sync.Mutex
+}
+
+func newDebouncer(window, maxDelay time.Duration) *debouncer {
+	if maxDelay < window {
+		maxDelay = window
+	}
+	return &debouncer{
+		window:   window,
+		maxDelay: maxDelay,
+		now:      time.Now,
+		afterFunc: func(d time.Duration, f func()) func() bool {
+			return time.AfterFunc(d, f).Stop
+		},
+		pending: map[string]*debouncedKey{},
+	}
+}
+
+// add records the event for the object. The add function is called with the latest object of the key once the
+// window passes.
+func (d *debouncer) add(obj runtime.Object, add func(obj runtime.Object)) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	key := objectKeyFor(obj)
+	now := d.now()
+	if pending, ok := d.pending[key]; ok {
+		pending.obj = obj
+		pending.deadline = now.Add(d.window)
+		if pending.deadline.After(pending.maxDeadline) {
+			pending.deadline = pending.maxDeadline
+		}
+		return
+	}
+	pending := &debouncedKey{obj: obj, deadline: now.Add(d.window), maxDeadline: now.Add(d.maxDelay)}
+	pending.stopTimer = d.afterFunc(d.window, func() { d.fire(key, add) })
+	d.pending[key] = pending
+}
+
+// fire queues the key when its deadline passed, otherwise it waits for the moved deadline.
+func (d *debouncer) fire(key string, add func(obj runtime.Object)) {
+	d.lock.Lock()
+	pending, ok := d.pending[key]
+	if !ok {
+		d.lock.Unlock()
+		return
+	}
+	if remaining := pending.deadline.Sub(d.now()); remaining > 0 {
+		pending.stopTimer = d.afterFunc(remaining, func() { d.fire(key, add) })
+		d.lock.Unlock()
+		return
+	}
+	delete(d.pending, key)
+	d.lock.Unlock()
+	add(pending.obj)
+}
+
+// stop cancels the scheduled fires and drops the pending keys when the controller shuts down. The queue of the stopped
+// controller is shut down and the restarted controller re-queues all objects from the informer caches.
+func (d *debouncer) stop() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for key, pending := range d.pending {
+		pending.stopTimer()
+		delete(d.pending, key)
+	}
+}
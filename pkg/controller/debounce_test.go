@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+type scheduledFunc struct {
+	at time.Time
+	f  func()
+}
+
+func TestDebouncer(t *testing.T) {
+	now := time.Now()
+	start := now
+	var scheduled []scheduledFunc
+	d := newDebouncer(time.Second, 3*time.Second)
+	d.now = func() time.Time { return now }
+	d.afterFunc = func(after time.Duration, f func()) func() bool {
+		scheduled = append(scheduled, scheduledFunc{at: now.Add(after), f: f})
+		return func() bool { return true }
+	}
+
+	var added []string
+	add := func(obj runtime.Object) {
+		added = append(added, obj.(*v1.Secret).ResourceVersion)
+	}
+	// advance runs the scheduled functions up to the given offset from start
+	advance := func(offset time.Duration) {
+		for len(scheduled) > 0 && !scheduled[0].at.After(start.Add(offset)) {
+			next := scheduled[0]
+			scheduled = scheduled[1:]
+			now = next.at
+			next.f()
+		}
+		now = start.Add(offset)
+	}
+
+	// the events within the window are collapsed
+	d.add(secretWithVersion("1"), add)
+	advance(500 * time.Millisecond)
+	d.add(secretWithVersion("2"), add)
+	advance(1200 * time.Millisecond)
+	if len(added) != 0 {
+		t.Fatalf("expected the window to be moved by the second event, got %v", added)
+	}
+	advance(1500 * time.Millisecond)
+	if len(added) != 1 || added[0] != "2" {
+		t.Fatalf("expected the latest object to be added once, got %v", added)
+	}
+
+	// sustained churn is capped by the max delay
+	added = nil
+	start = now
+	for i := 0; i < 10; i++ {
+		d.add(secretWithVersion(strconv.Itoa(i)), add)
+		advance(time.Duration(i+1) * 500 * time.Millisecond)
+	}
+	if len(added) == 0 || added[0] != "5" {
+		t.Fatalf("expected the object to be added after the max delay, got %v", added)
+	}
+}
+
+func TestDebouncerKeyedByKind(t *testing.T) {
+	d := newDebouncer(time.Hour, time.Hour)
+	d.afterFunc = func(time.Duration, func()) func() bool { return func() bool { return true } }
+	d.add(&v1.Secret{ObjectMeta: meta.ObjectMeta{Namespace: "test", Name: "foo"}}, func(runtime.Object) {})
+	d.add(&v1.ConfigMap{ObjectMeta: meta.ObjectMeta{Namespace: "test", Name: "foo"}}, func(runtime.Object) {})
+	if len(d.pending) != 2 {
+		t.Errorf("expected both objects to be debounced, got %v", d.pending)
+	}
+}
+
+func TestDebouncerStop(t *testing.T) {
+	stopped := 0
+	d := newDebouncer(time.Hour, time.Hour)
+	d.afterFunc = func(time.Duration, func()) func() bool {
+		return func() bool {
+			stopped++
+			return true
+		}
+	}
+	d.add(&v1.Secret{ObjectMeta: meta.ObjectMeta{Namespace: "test", Name: "foo"}}, func(runtime.Object) {})
+	d.add(&v1.Secret{ObjectMeta: meta.ObjectMeta{Namespace: "test", Name: "bar"}}, func(runtime.Object) {})
+
+	d.stop()
+	if stopped != 2 || len(d.pending) != 0 {
+		t.Errorf("expected the pending timers to be stopped, got %d stopped and pending %v", stopped, d.pending)
+	}
+}
+
+func TestDebouncedSync(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(makeFakeSecret())
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go kubeInformers.Start(ctx.Done())
+
+	var lock sync.Mutex
+	var syncedVersions []string
+	controller := NewFactory().
+		Informers(kubeInformers.Core().V1().Secrets().Informer()).
+		WithDebounce(500*time.Millisecond, 5*time.Second).
+		Sync(func(ctx context.Context, controllerContext Context) error {
+			lock.Lock()
+			defer lock.Unlock()
+			syncedVersions = append(syncedVersions, controllerContext.GetObjectMeta().GetLabels()["version"])
+			return nil
+		}).Controller("DebounceController", events.NewInMemoryRecorder("debounce-controller"))
+	go controller.Run(ctx, 1)
+
+	syncs := func(expected int) {
+		if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return len(syncedVersions) >= expected, nil
+		}); err != nil {
+			t.Fatalf("expected %d syncs, got %v", expected, syncedVersions)
+		}
+	}
+	syncs(1)
+
+	secret := makeFakeSecret()
+	for i := 1; i <= 10; i++ {
+		secret.Labels = map[string]string{"version": strconv.Itoa(i)}
+		if _, err := kubeClient.CoreV1().Secrets("test").Update(secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	syncs(2)
+
+	// make sure the burst was not synced more than once
+	time.Sleep(time.Second)
+	lock.Lock()
+	defer lock.Unlock()
+	if len(syncedVersions) != 2 || syncedVersions[1] != "10" {
+		t.Errorf("expected the burst of updates to be synced once with the latest object, got %v", syncedVersions)
+	}
+}
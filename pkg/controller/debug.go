@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// maxRecentErrors is the number of recent sync errors kept per object.
+const maxRecentErrors = 5
+
+// Worker states reported in the debug info.
+const (
+	WorkerStateIdle    = "idle"
+	WorkerStateSyncing = "syncing"
+	WorkerStateStopped = "stopped"
+)
+
+// DebugInfo is a snapshot of the controller internal state.
+type DebugInfo struct {
+	Name        string              `json:"name"`
+	QueueDepth  int                 `json:"queueDepth"`
+	InFlight    []InFlightDebugInfo `json:"inFlight"`
+	Errors      []ErrorDebugInfo    `json:"errors"`
+	Workers     []WorkerDebugInfo   `json:"workers"`
+	Informers   []InformerDebugInfo `json:"informers"`
+	Quarantined []string            `json:"quarantined"`
+}
+
+// InFlightDebugInfo describes the sync that is currently running.
+type InFlightDebugInfo struct {
+	Key     string    `json:"key"`
+	Kind    string    `json:"kind"`
+	Worker  int       `json:"worker"`
+	Started time.Time `json:"started"`
+}
+
+// ErrorDebugInfo describes recent sync errors for single object.
+type ErrorDebugInfo struct {
+	Key  string `json:"key"`
+	Kind string `json:"kind"`
+	// Retries is the number of consecutive sync failures.
+	Retries int               `json:"retries"`
+	Recent  []SyncErrorRecord `json:"recent"`
+}
+
+// SyncErrorRecord is a single sync error.
+type SyncErrorRecord struct {
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// WorkerDebugInfo describes the state of single worker.
+type WorkerDebugInfo struct {
+	ID    int       `json:"id"`
+	State string    `json:"state"`
+	Key   string    `json:"key,omitempty"`
+	Since time.Time `json:"since"`
+}
+
+// InformerDebugInfo describes the informer cache sync status.
+type InformerDebugInfo struct {
+	Name   string `json:"name"`
+	Synced bool   `json:"synced"`
+}
+
+// DebugHandler returns HTTP handler that serves debug info of the given controllers as JSON.
+// The handler can be mounted on an existing mux, for example:
+//
+//	mux.Handle("/debug/controllers", controller.DebugHandler(secretController, configMapController))
+//
+// The optional "name" query parameter filters the output to a single controller.
+func DebugHandler(controllers ...Controller) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		result := []DebugInfo{}
+		for _, c := range controllers {
+			info := c.DebugInfo()
+			if len(name) > 0 && info.Name != name {
+				continue
+			}
+			result = append(result, info)
+		}
+		if len(name) > 0 && len(result) == 0 {
+			http.Error(w, "controller "+name+" not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// syncTracker records the worker states and recent sync errors.
+type syncTracker struct {
+	workers map[int]*workerState
+	errors  map[string]*ErrorDebugInfo
+	lock    sync.Mutex
+}
+
+type workerState struct {
+	state string
+	key   string
+	kind  string
+	since time.Time
+}
+
+func newSyncTracker() *syncTracker {
+	return &syncTracker{
+		workers: map[int]*workerState{},
+		errors:  map[string]*ErrorDebugInfo{},
+	}
+}
+
+func (t *syncTracker) setWorkerState(workerID int, state string, obj runtime.Object) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.workers[workerID] = &workerState{
+		state: state,
+		key:   queueKeyFor(obj),
+		kind:  objectKind(obj),
+		since: time.Now(),
+	}
+}
+
+func (t *syncTracker) workerIdle(workerID int) {
+	t.setWorkerState(workerID, WorkerStateIdle, nil)
+}
+
+func (t *syncTracker) workerStopped(workerID int) {
+	t.setWorkerState(workerID, WorkerStateStopped, nil)
+}
+
+func (t *syncTracker) syncStarted(workerID int, obj runtime.Object) {
+	t.setWorkerState(workerID, WorkerStateSyncing, obj)
+}
+
+func (t *syncTracker) syncFinished(workerID int) {
+	t.workerIdle(workerID)
+}
+
+func (t *syncTracker) syncFailed(obj runtime.Object, err error, failures int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key := objectKeyFor(obj)
+	info, ok := t.errors[key]
+	if !ok {
+		info = &ErrorDebugInfo{Key: queueKeyFor(obj), Kind: objectKind(obj)}
+		t.errors[key] = info
+	}
+	info.Retries = failures
+	info.Recent = append(info.Recent, SyncErrorRecord{Error: err.Error(), Time: time.Now()})
+	if len(info.Recent) > maxRecentErrors {
+		info.Recent = info.Recent[len(info.Recent)-maxRecentErrors:]
+	}
+}
+
+func (t *syncTracker) syncSucceeded(obj runtime.Object) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.errors, objectKeyFor(obj))
+}
+
+// forget removes the recent sync errors of the deleted object.
+func (t *syncTracker) forget(obj runtime.Object) {
+	t.syncSucceeded(obj)
+}
+
+func (t *syncTracker) debugInfo() DebugInfo {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	info := DebugInfo{}
+	for id, w := range t.workers {
+		info.Workers = append(info.Workers, WorkerDebugInfo{ID: id, State: w.state, Key: w.key, Since: w.since})
+		if w.state == WorkerStateSyncing {
+			info.InFlight = append(info.InFlight, InFlightDebugInfo{Key: w.key, Kind: w.kind, Worker: id, Started: w.since})
+		}
+	}
+	for _, e := range t.errors {
+		info.Errors = append(info.Errors, ErrorDebugInfo{Key: e.Key, Kind: e.Kind, Retries: e.Retries, Recent: append([]SyncErrorRecord{}, e.Recent...)})
+	}
+	sort.Slice(info.Workers, func(i, j int) bool { return info.Workers[i].ID < info.Workers[j].ID })
+	sort.Slice(info.InFlight, func(i, j int) bool { return info.InFlight[i].Worker < info.InFlight[j].Worker })
+	sort.Slice(info.Errors, func(i, j int) bool {
+		if info.Errors[i].Kind != info.Errors[j].Kind {
+			return info.Errors[i].Kind < info.Errors[j].Kind
+		}
+		return info.Errors[i].Key < info.Errors[j].Key
+	})
+	return info
+}
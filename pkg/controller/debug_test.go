@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func getDebugInfo(t *testing.T, server *httptest.Server, query string) ([]DebugInfo, int) {
+	resp, err := http.Get(server.URL + query)
+	if err != nil {
+		t.Fatalf("failed to get debug info: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode
+	}
+	var result []DebugInfo
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode debug info: %v", err)
+	}
+	return result, resp.StatusCode
+}
+
+func TestDebugHandler(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(makeFakeSecret())
+
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	go kubeInformers.Start(ctx.Done())
+
+	syncStarted := make(chan struct{})
+	finishSync := make(chan struct{})
+	controller := NewFactory().NamedInformer("secrets", kubeInformers.Core().V1().Secrets().Informer()).Sync(func(ctx context.Context, controllerContext Context) error {
+		select {
+		case syncStarted <- struct{}{}:
+		default:
+		}
+		<-finishSync
+		return fmt.Errorf("sync failed")
+	}).Controller("DebugController", events.NewInMemoryRecorder("debug-controller"))
+
+	server := httptest.NewServer(DebugHandler(controller))
+	defer server.Close()
+
+	go controller.Run(ctx, 1)
+
+	select {
+	case <-syncStarted:
+	case <-time.After(30 * time.Second):
+		t.Fatal("test timeout")
+	}
+
+	result, _ := getDebugInfo(t, server, "")
+	if len(result) != 1 || result[0].Name != "DebugController" {
+		t.Fatalf("expected debug info for DebugController, got %#v", result)
+	}
+	info := result[0]
+	if len(info.InFlight) != 1 || info.InFlight[0].Key != "test/test-secret" || info.InFlight[0].Kind != "Secret" || info.InFlight[0].Worker != 1 {
+		t.Errorf("expected test-secret sync in flight, got %#v", info.InFlight)
+	}
+	if len(info.Workers) != 1 || info.Workers[0].State != WorkerStateSyncing {
+		t.Errorf("expected worker to be syncing, got %#v", info.Workers)
+	}
+	if len(info.Informers) != 1 || info.Informers[0].Name != "secrets" || !info.Informers[0].Synced {
+		t.Errorf("expected secrets informer to be synced, got %#v", info.Informers)
+	}
+
+	close(finishSync)
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		result, _ := getDebugInfo(t, server, "?name=DebugController")
+		return len(result) == 1 && len(result[0].Errors) == 1 && result[0].Errors[0].Retries >= 2, nil
+	}); err != nil {
+		t.Fatalf("expected sync errors to be reported, got %#v", controller.DebugInfo())
+	}
+	info = controller.DebugInfo()
+	if info.Errors[0].Key != "test/test-secret" || info.Errors[0].Kind != "Secret" || info.Errors[0].Recent[0].Error != "sync failed" {
+		t.Errorf("unexpected sync errors: %#v", info.Errors)
+	}
+	if result, _ := getDebugInfo(t, server, "?name=DebugController"); result[0].Errors[0].Kind != "Secret" {
+		t.Errorf("expected the kind of the failing object to be served, got %#v", result[0].Errors)
+	}
+
+	if _, status := getDebugInfo(t, server, "?name=Unknown"); status != http.StatusNotFound {
+		t.Errorf("expected unknown controller to return 404, got %d", status)
+	}
+}
+
+func TestSyncErrorsKeyedByKind(t *testing.T) {
+	tracker := newSyncTracker()
+	tracker.syncFailed(&v1.Secret{ObjectMeta: meta.ObjectMeta{Namespace: "test", Name: "foo"}}, fmt.Errorf("secret failed"), 1)
+	tracker.syncFailed(&v1.ConfigMap{ObjectMeta: meta.ObjectMeta{Namespace: "test", Name: "foo"}}, fmt.Errorf("config map failed"), 2)
+
+	errors := tracker.debugInfo().Errors
+	if len(errors) != 2 {
+		t.Fatalf("expected errors of both objects, got %#v", errors)
+	}
+	if errors[0].Kind != "ConfigMap" || errors[0].Retries != 2 || errors[1].Kind != "Secret" || errors[1].Retries != 1 {
+		t.Errorf("expected errors sorted by kind, got %#v", errors)
+	}
+}
+
+func TestSyncErrorsForgotten(t *testing.T) {
+	secret := &v1.Secret{ObjectMeta: meta.ObjectMeta{Namespace: "test", Name: "foo"}}
+	tracker := newSyncTracker()
+	tracker.syncFailed(secret, fmt.Errorf("failed"), 1)
+	tracker.forget(secret)
+	if errors := tracker.debugInfo().Errors; len(errors) != 0 {
+		t.Errorf("expected the sync errors of the deleted object to be forgotten, got %#v", errors)
+	}
+}
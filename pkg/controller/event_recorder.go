@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// EventRateLimits configures the token buckets of the rate-limited event recorder.
+type EventRateLimits struct {
+	// PerReasonInterval is how often a token is added to the bucket shared by all events with the same reason.
+	PerReasonInterval time.Duration
+	// PerReasonBurst is the size of the bucket shared by all events with the same reason.
+	PerReasonBurst int
+	// PerObjectInterval is how often a token is added to the bucket of events with the same reason recorded during
+	// sync of single object.
+	PerObjectInterval time.Duration
+	// PerObjectBurst is the size of the bucket of events with the same reason recorded during sync of single object.
+	PerObjectBurst int
+}
+
+// DefaultEventRateLimits are the event rate limits used by controllers unless configured otherwise.
+var DefaultEventRateLimits = EventRateLimits{
+	PerReasonInterval: time.Second,
+	PerReasonBurst:    25,
+	PerObjectInterval: time.Minute,
+	PerObjectBurst:    5,
+}
+
+// NewRateLimitedRecorder returns events.Recorder that protects the API server from event floods, for example from
+// controller in a hot error loop. Events are limited by token bucket per reason and, when recorded during sync of an
+// object, by token bucket per object and reason.
+// Events dropped by the rate limiting are not lost completely. When the same message is recorded again and passes the
+// limits, it reports how many times it occurred, like "message (occurred 5 times)".
+func NewRateLimitedRecorder(recorder events.Recorder, limits EventRateLimits) events.Recorder {
+	return &rateLimitedRecorder{
+		recorder: recorder,
+		limiter: &eventRateLimiter{
+			limits:  limits,
+			now:     time.Now,
+			reasons: map[string]*rate.Limiter{},
+			objects: map[objectReason]*objectEvents{},
+		},
+	}
+}
+
+// rateLimitedRecorder is events.Recorder decorator. The events.Recorder does not know about the involved object, so the
+// controller scopes the recorder to the currently synced object via forObject().
+type rateLimitedRecorder struct {
+	recorder events.Recorder
+	limiter  *eventRateLimiter
+	// object is the key of the object being synced, empty when the events are not recorded during object sync.
+	object string
+}
+
+var _ events.Recorder = &rateLimitedRecorder{}
+
+func (r *rateLimitedRecorder) Event(reason, message string) {
+	if message, ok := r.limiter.allow(r.object, reason, message); ok {
+		r.recorder.Event(reason, message)
+	}
+}
+
+func (r *rateLimitedRecorder) Eventf(reason, messageFmt string, args ...interface{}) {
+	r.Event(reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *rateLimitedRecorder) Warning(reason, message string) {
+	if message, ok := r.limiter.allow(r.object, reason, message); ok {
+		r.recorder.Warning(reason, message)
+	}
+}
+
+func (r *rateLimitedRecorder) Warningf(reason, messageFmt string, args ...interface{}) {
+	r.Warning(reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *rateLimitedRecorder) ForComponent(componentName string) events.Recorder {
+	return &rateLimitedRecorder{recorder: r.recorder.ForComponent(componentName), limiter: r.limiter, object: r.object}
+}
+
+func (r *rateLimitedRecorder) WithComponentSuffix(componentNameSuffix string) events.Recorder {
+	return &rateLimitedRecorder{recorder: r.recorder.WithComponentSuffix(componentNameSuffix), limiter: r.limiter, object: r.object}
+}
+
+func (r *rateLimitedRecorder) ComponentName() string {
+	return r.recorder.ComponentName()
+}
+
+// forObject returns the recorder that counts the events to the per-object buckets of the given object.
+func (r *rateLimitedRecorder) forObject(obj runtime.Object) events.Recorder {
+	return &rateLimitedRecorder{recorder: r.recorder, limiter: r.limiter, object: objectKeyFor(obj)}
+}
+
+// eventsForObject scopes the recorder to the given object when it is rate-limited.
+func eventsForObject(recorder events.Recorder, obj runtime.Object) events.Recorder {
+	if r, ok := recorder.(*rateLimitedRecorder); ok {
+		return r.forObject(obj)
+	}
+	return recorder
+}
+
+type objectReason struct {
+	object string
+	reason string
+}
+
+type objectEvents struct {
+	// limiter is nil for events not recorded during object sync, these are limited only per reason.
+	limiter *rate.Limiter
+	// suppressed counts the dropped events per message.
+	suppressed map[string]int
+	lastSeen   time.Time
+}
+
+// eventRateLimiter holds the token buckets shared by all copies of the rate-limited recorder.
+type eventRateLimiter struct {
+	limits    EventRateLimits
+	now       func() time.Time
+	reasons   map[string]*rate.Limiter
+	objects   map[objectReason]*objectEvents
+	lastPrune time.Time
+	lock      sync.Mutex
+}
+
+// allow returns whether the event can be recorded and the message to record, which includes the number of occurrences
+// when the same message was dropped before.
+func (l *eventRateLimiter) allow(object, reason, message string) (string, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.now()
+	l.prune(now)
+
+	key := objectReason{object: object, reason: reason}
+	state, ok := l.objects[key]
+	if !ok {
+		state = &objectEvents{suppressed: map[string]int{}}
+		if len(object) > 0 {
+			state.limiter = rate.NewLimiter(rate.Every(l.limits.PerObjectInterval), l.limits.PerObjectBurst)
+		}
+		l.objects[key] = state
+	}
+	state.lastSeen = now
+
+	reasonLimiter, ok := l.reasons[reason]
+	if !ok {
+		reasonLimiter = rate.NewLimiter(rate.Every(l.limits.PerReasonInterval), l.limits.PerReasonBurst)
+		l.reasons[reason] = reasonLimiter
+	}
+
+	var allowed bool
+	if state.limiter != nil {
+		// the object token is returned when the reason bucket is empty
+		reservation := state.limiter.ReserveN(now, 1)
+		allowed = reservation.DelayFrom(now) == 0 && reasonLimiter.AllowN(now, 1)
+		if !allowed {
+			reservation.CancelAt(now)
+		}
+	} else {
+		allowed = reasonLimiter.AllowN(now, 1)
+	}
+
+	if !allowed {
+		state.suppressed[message]++
+		return "", false
+	}
+	if suppressed := state.suppressed[message]; suppressed > 0 {
+		delete(state.suppressed, message)
+		return fmt.Sprintf("%s (occurred %d times)", message, suppressed+1), true
+	}
+	return message, true
+}
+
+// prune forgets the objects that did not record any event for long enough to have their buckets full again.
+func (l *eventRateLimiter) prune(now time.Time) {
+	idle := l.limits.PerObjectInterval * time.Duration(l.limits.PerObjectBurst)
+	if now.Sub(l.lastPrune) < idle {
+		return
+	}
+	l.lastPrune = now
+	for key, state := range l.objects {
+		if now.Sub(state.lastSeen) >= idle {
+			delete(l.objects, key)
+		}
+	}
+}
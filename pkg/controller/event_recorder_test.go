@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func TestRateLimitedRecorder(t *testing.T) {
+	inMemory := events.NewInMemoryRecorder("test")
+	now := time.Now()
+	recorder := NewRateLimitedRecorder(inMemory, EventRateLimits{
+		PerReasonInterval: time.Second,
+		PerReasonBurst:    3,
+		PerObjectInterval: time.Minute,
+		PerObjectBurst:    2,
+	}).(*rateLimitedRecorder)
+	recorder.limiter.now = func() time.Time { return now }
+
+	messages := func() []string {
+		result := []string{}
+		for _, e := range inMemory.Events() {
+			result = append(result, e.Reason+": "+e.Message)
+		}
+		return result
+	}
+	expectMessages := func(expected ...string) {
+		t.Helper()
+		got := messages()
+		if len(got) != len(expected) {
+			t.Fatalf("expected %d events, got %#v", len(expected), got)
+		}
+		for i := range expected {
+			if got[i] != expected[i] {
+				t.Errorf("expected event %q, got %q", expected[i], got[i])
+			}
+		}
+	}
+
+	// per-reason bucket
+	for i := 0; i < 5; i++ {
+		recorder.Warning("Failed", "boom")
+	}
+	recorder.Event("Other", "ok")
+	expectMessages("Failed: boom", "Failed: boom", "Failed: boom", "Other: ok")
+
+	now = now.Add(time.Second)
+	recorder.Warning("Failed", "boom")
+	expectMessages("Failed: boom", "Failed: boom", "Failed: boom", "Other: ok", "Failed: boom (occurred 3 times)")
+
+	// per-object bucket
+	now = now.Add(time.Hour)
+	secret := makeFakeSecret()
+	objectRecorder := eventsForObject(recorder, secret)
+	for i := 0; i < 3; i++ {
+		objectRecorder.Warningf("SecretFailed", "secret %s failed", secret.Name)
+	}
+	// events for other objects are not affected
+	otherSecret := makeFakeSecret()
+	otherSecret.Name = "other-secret"
+	eventsForObject(recorder, otherSecret).Warningf("SecretFailed", "secret %s failed", otherSecret.Name)
+	expectMessages("Failed: boom", "Failed: boom", "Failed: boom", "Other: ok", "Failed: boom (occurred 3 times)",
+		"SecretFailed: secret test-secret failed", "SecretFailed: secret test-secret failed", "SecretFailed: secret other-secret failed")
+
+	now = now.Add(time.Minute)
+	objectRecorder.Warningf("SecretFailed", "secret %s failed", secret.Name)
+	if got := messages(); got[len(got)-1] != "SecretFailed: secret test-secret failed (occurred 2 times)" {
+		t.Errorf("expected aggregated event, got %q", got[len(got)-1])
+	}
+
+	if recorder.WithComponentSuffix("foo").ComponentName() != "test-foo" {
+		t.Errorf("expected component suffix to be passed to the recorder")
+	}
+}
+
+func TestControllerEventsRateLimited(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		factory  func(*Factory) *Factory
+		expected int
+	}{
+		{name: "default", factory: func(f *Factory) *Factory { return f }, expected: DefaultEventRateLimits.PerObjectBurst},
+		{name: "disabled", factory: func(f *Factory) *Factory { return f.WithoutEventRateLimits() }, expected: 20},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeClient := fake.NewSimpleClientset(makeFakeSecret())
+			kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+			go kubeInformers.Start(ctx.Done())
+
+			synced := make(chan struct{})
+			recorder := events.NewInMemoryRecorder("rate-limited-controller")
+			controller := tc.factory(NewFactory()).Informers(kubeInformers.Core().V1().Secrets().Informer()).Sync(func(ctx context.Context, controllerContext Context) error {
+				defer close(synced)
+				for i := 0; i < 20; i++ {
+					controllerContext.Events().Warning("HotLoop", "still failing")
+				}
+				return nil
+			}).Controller("RateLimitedController", recorder)
+			go controller.Run(ctx, 1)
+
+			select {
+			case <-synced:
+			case <-time.After(30 * time.Second):
+				t.Fatal("test timeout")
+			}
+			if got := len(recorder.Events()); got != tc.expected {
+				t.Errorf("expected %d events, got %d", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DefaultExpectationsTimeout is how long the controller waits for the informers to observe the child objects created or
+// deleted by Sync() before it syncs the object anyway. This is the same timeout the kube-controller-manager uses.
+const DefaultExpectationsTimeout = 5 * time.Minute
+
+// expectation counts the child creations and deletions that were not observed by the child informers yet.
+type expectation struct {
+	creations int
+	deletions int
+	expires   time.Time
+}
+
+func (e *expectation) satisfied() bool {
+	return e.creations <= 0 && e.deletions <= 0
+}
+
+// expectations tracks the child objects created or deleted by Sync() per owner object, so the owner is not
+// synced before the child informers caught up. Without it, the sync would see the old children and create or delete
+// them again.
+type expectations struct {
+	timeout time.Duration
+	now     func() time.Time
+	pending map[string]*expectation
+	lock    sync.Mutex
+}
+
+func newExpectations(timeout time.Duration) *expectations {
+	return &expectations{
+		timeout: timeout,
+		now:     time.Now,
+		pending: map[string]*expectation{},
+	}
+}
+
+// expect raises the expected creations and deletions for the key and resets the timeout.
+func (e *expectations) expect(key string, creations, deletions int) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	now := e.now()
+	current, ok := e.pending[key]
+	if !ok || !now.Before(current.expires) {
+		current = &expectation{}
+		e.pending[key] = current
+	}
+	current.creations += creations
+	current.deletions += deletions
+	current.expires = now.Add(e.timeout)
+}
+
+// lower lowers the expected creations and deletions for the key.
+func (e *expectations) lower(key string, creations, deletions int) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if current, ok := e.pending[key]; ok {
+		current.creations -= creations
+		current.deletions -= deletions
+	}
+}
+
+// observe forgets the expectations of the deleted object.
+func (e *expectations) observe(obj runtime.Object, eventType string) {
+	if eventType != SyncEventDelete {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	delete(e.pending, objectKeyFor(obj))
+}
+
+// unsatisfied returns true when the object still waits for its children to be observed and should not be synced.
+// The returned duration is how long to wait before the expectations expire.
+func (e *expectations) unsatisfied(obj runtime.Object) (time.Duration, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	key := objectKeyFor(obj)
+	current, ok := e.pending[key]
+	if !ok {
+		return 0, false
+	}
+	now := e.now()
+	if current.satisfied() || !now.Before(current.expires) {
+		delete(e.pending, key)
+		return 0, false
+	}
+	return current.expires.Sub(now), true
+}
+
+// ExpectCreations records that the synced object expects the given number of its children to be created. The object is
+// not synced again until the child informers observe the creations or the expectations timeout expires.
+func (c controllerContext) ExpectCreations(count int) {
+	if key := objectKeyFor(c.queueObject); len(key) > 0 && c.expectations != nil {
+		c.expectations.expect(key, count, 0)
+	}
+}
+
+// ExpectDeletions records that the synced object expects the given number of its children to be deleted. The object is
+// not synced again until the child informers observe the deletions or the expectations timeout expires.
+func (c controllerContext) ExpectDeletions(count int) {
+	if key := objectKeyFor(c.queueObject); len(key) > 0 && c.expectations != nil {
+		c.expectations.expect(key, 0, count)
+	}
+}
+
+// LowerExpectations lowers the expectations of the synced object, for example when some of the creates or deletes
+// failed and the informers will never observe them.
+func (c controllerContext) LowerExpectations(creations, deletions int) {
+	if key := objectKeyFor(c.queueObject); len(key) > 0 && c.expectations != nil {
+		c.expectations.lower(key, creations, deletions)
+	}
+}
+
+// childObserved lowers the expectations of the owner of the child object observed by child informer and re-queues the
+// owner. The owner is the controller reference of the child and it must be in the caches of the controller informers.
+func (c *controllerContext) childObserved(child runtime.Object, eventType string) {
+	owner := c.findOwner(child)
+	if owner == nil {
+		return
+	}
+	switch eventType {
+	case SyncEventAdd:
+		c.expectations.lower(objectKeyFor(owner), 1, 0)
+	case SyncEventDelete:
+		c.expectations.lower(objectKeyFor(owner), 0, 1)
+	}
+	c.enqueue(owner, SyncEventChild, false)
+}
+
+// findOwner returns the object that is the controller reference of the child from the controller informer caches, or
+// nil when the child has no controller or the owner is not watched by the controller.
+func (c *controllerContext) findOwner(child runtime.Object) runtime.Object {
+	childMeta, err := meta.Accessor(child)
+	if err != nil {
+		return nil
+	}
+	ref := metav1.GetControllerOf(childMeta)
+	if ref == nil {
+		return nil
+	}
+	// the owner is either in the same namespace as the child or cluster-scoped
+	keys := []string{ref.Name}
+	if len(childMeta.GetNamespace()) > 0 {
+		keys = []string{childMeta.GetNamespace() + "/" + ref.Name, ref.Name}
+	}
+	for _, informer := range c.owners {
+		for _, key := range keys {
+			item, exists, err := informer.GetStore().GetByKey(key)
+			if err != nil || !exists {
+				continue
+			}
+			owner, ok := item.(runtime.Object)
+			if !ok {
+				continue
+			}
+			if ownerMeta, err := meta.Accessor(owner); err == nil && ownerMeta.GetUID() == ref.UID {
+				return owner
+			}
+		}
+	}
+	return nil
+}
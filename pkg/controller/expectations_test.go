@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func TestExpectations(t *testing.T) {
+	now := time.Now()
+	e := newExpectations(time.Minute)
+	e.now = func() time.Time { return now }
+	secret := makeFakeSecret()
+	key := objectKeyFor(secret)
+
+	if _, unsatisfied := e.unsatisfied(secret); unsatisfied {
+		t.Fatalf("expected object without expectations to be synced")
+	}
+
+	e.expect(key, 2, 1)
+	now = now.Add(20 * time.Second)
+	if delay, unsatisfied := e.unsatisfied(secret); !unsatisfied || delay != 40*time.Second {
+		t.Errorf("expected unsatisfied expectations to be rechecked after 40s, got unsatisfied=%v after %v", unsatisfied, delay)
+	}
+	e.lower(key, 2, 0)
+	if _, unsatisfied := e.unsatisfied(secret); !unsatisfied {
+		t.Errorf("expected expectations with pending deletion to be unsatisfied")
+	}
+	e.lower(key, 0, 1)
+	if _, unsatisfied := e.unsatisfied(secret); unsatisfied {
+		t.Errorf("expected expectations to be satisfied")
+	}
+
+	e.expect(key, 1, 0)
+	now = now.Add(time.Minute)
+	if _, unsatisfied := e.unsatisfied(secret); unsatisfied {
+		t.Errorf("expected expectations to expire")
+	}
+
+	e.expect(key, 1, 0)
+	e.observe(secret, SyncEventDelete)
+	if _, unsatisfied := e.unsatisfied(secret); unsatisfied {
+		t.Errorf("expected expectations of deleted object to be forgotten")
+	}
+}
+
+func TestExpectationsKeyedByKind(t *testing.T) {
+	secret := &v1.Secret{ObjectMeta: meta.ObjectMeta{Namespace: "test", Name: "foo"}}
+	configMap := &v1.ConfigMap{ObjectMeta: meta.ObjectMeta{Namespace: "test", Name: "foo"}}
+
+	e := newExpectations(time.Minute)
+	e.expect(objectKeyFor(secret), 1, 0)
+	if _, ok := e.unsatisfied(configMap); ok {
+		t.Errorf("expected the config map to not have the secret expectations")
+	}
+	e.observe(configMap, SyncEventDelete)
+	if _, ok := e.unsatisfied(secret); !ok {
+		t.Errorf("expected the secret expectations to be kept when the config map is deleted")
+	}
+}
+
+func TestChildInformers(t *testing.T) {
+	parent := makeFakeSecret()
+	parent.UID = "parent-uid"
+	kubeClient := fake.NewSimpleClientset(parent)
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go kubeInformers.Start(ctx.Done())
+
+	childLister := kubeInformers.Core().V1().ConfigMaps().Lister()
+	var lock sync.Mutex
+	var observedChildren []int
+	controller := NewFactory().
+		Informers(kubeInformers.Core().V1().Secrets().Informer()).
+		ChildInformers(kubeInformers.Core().V1().ConfigMaps().Informer()).
+		Sync(func(ctx context.Context, controllerContext Context) error {
+			children, err := childLister.ConfigMaps("test").List(labels.Everything())
+			if err != nil {
+				return err
+			}
+			lock.Lock()
+			observedChildren = append(observedChildren, len(children))
+			lock.Unlock()
+			if len(children) > 0 {
+				return nil
+			}
+
+			owner := controllerContext.GetQueueObject().(*v1.Secret)
+			controllerContext.ExpectCreations(3)
+			for i := 0; i < 3; i++ {
+				_, err := kubeClient.CoreV1().ConfigMaps("test").Create(&v1.ConfigMap{
+					ObjectMeta: meta.ObjectMeta{
+						Name:            fmt.Sprintf("child-%d", i),
+						Namespace:       "test",
+						OwnerReferences: []meta.OwnerReference{*meta.NewControllerRef(owner, v1.SchemeGroupVersion.WithKind("Secret"))},
+					},
+				})
+				if err != nil {
+					controllerContext.LowerExpectations(1, 0)
+				}
+			}
+			// this would sync the owner before the children are observed without the expectations
+			controllerContext.Queue().Add(owner)
+			return nil
+		}).Controller("ExpectationsController", events.NewInMemoryRecorder("expectations-controller"))
+	go controller.Run(ctx, 1)
+
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(observedChildren) > 1, nil
+	}); err != nil {
+		t.Fatalf("expected the owner to be synced again when the children were observed, got %v", observedChildren)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if observedChildren[0] != 0 {
+		t.Errorf("expected the first sync to observe no children, got %v", observedChildren)
+	}
+	for _, children := range observedChildren[1:] {
+		if children != 3 {
+			t.Errorf("expected the owner to be synced only after all children were observed, got %v", observedChildren)
+		}
+	}
+}
@@ -2,10 +2,15 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/client-go/util/workqueue"
 )
 
@@ -17,10 +22,35 @@ type SyncFunc func(ctx context.Context, controllerContext Context) error
 // Factory is generator that generate standard Kubernetes controllers.
 // Factory is really generic and should be only used for simple controllers that does not require special stuff..
 type Factory struct {
-	sync           SyncFunc
-	resyncInterval time.Duration
-	informers      []cache.SharedInformer
-	cachesToSync   []cache.InformerSynced
+	sync             SyncFunc
+	resyncInterval   time.Duration
+	informers        []namedInformer
+	informerStarters []InformerStarter
+	informersCtx     context.Context
+	informerIndexers map[string]cache.Indexers
+	cacheSyncTimeout time.Duration
+	logger           Logger
+	tracerProvider   TracerProvider
+	recoverPanics    bool
+	metrics          MetricsProvider
+	quarantineAt     int
+	rateLimiter      workqueue.RateLimiter
+	applyClient      dynamic.Interface
+	conflictBackoff  *wait.Backoff
+	writeTimeout     time.Duration
+	expectationsTTL  time.Duration
+	scope            informerScope
+	scopedInformers  []scopedInformers
+	transform        TransformFunc
+	debounceWindow   time.Duration
+	debounceMaxDelay time.Duration
+
+	eventRateLimits        EventRateLimits
+	disableEventRateLimits bool
+
+	syncErrorEvents         bool
+	syncErrorEventsRecorder record.EventRecorder
+	syncErrorEventsInterval time.Duration
 }
 
 // NewFactory return new factory instance.
@@ -39,7 +69,94 @@ func (f *Factory) Sync(syncFn SyncFunc) *Factory {
 // Pass informers you want to use to react to changes on resources. If informer event is observed, then the Sync() function
 // is called.
 func (f *Factory) Informers(informers ...cache.SharedInformer) *Factory {
-	f.informers = informers
+	for i := range informers {
+		f.NamedInformer(fmt.Sprintf("informer-%d", len(f.informers)), informers[i])
+	}
+	return f
+}
+
+// NamedInformer is same as Informers() except the informer is identified by the given name in debug output and errors.
+func (f *Factory) NamedInformer(name string, informer cache.SharedInformer) *Factory {
+	f.informers = append(f.informers, namedInformer{name: name, informer: informer})
+	return f
+}
+
+// ClusterInformers is same as Informers() except the informers are tagged with the given cluster name. This allows single
+// controller to react to changes in multiple clusters. The cluster name is part of the queue key, so the objects with
+// the same namespace and name in different clusters are synced separately. Sync() gets the cluster via ClusterName().
+func (f *Factory) ClusterInformers(cluster string, informers ...cache.SharedInformer) *Factory {
+	for i := range informers {
+		f.informers = append(f.informers, namedInformer{
+			name:     fmt.Sprintf("%s/informer-%d", cluster, len(f.informers)),
+			cluster:  cluster,
+			informer: informers[i],
+		})
+	}
+	return f
+}
+
+// ChildInformers registers informers for the child objects created by the controller, like Pods or Jobs. The child
+// objects are not synced, instead their additions and deletions lower the expectations recorded in Sync() via
+// controllerContext.ExpectCreations() and ExpectDeletions() and their owner is re-queued. The owner is the controller
+// reference of the child and must be watched by the informers passed to Informers() or NamedInformer().
+// The owner is not synced until its expectations are satisfied or WithExpectationsTimeout() expires.
+func (f *Factory) ChildInformers(informers ...cache.SharedInformer) *Factory {
+	for i := range informers {
+		f.informers = append(f.informers, namedInformer{
+			name:     fmt.Sprintf("child-informer-%d", len(f.informers)),
+			child:    true,
+			informer: informers[i],
+		})
+	}
+	return f
+}
+
+// WithIndexers adds the indexers to the informer with the given name, so Sync() can look up the objects via
+// controllerContext.ByIndex() instead of scanning the whole lister. The name is the one given to NamedInformer(), or
+// "informer-N" for the informers added via Informers(), or the name of the informers built by KubeInformer(),
+// DynamicInformers() and MetadataInformers(), in which case the indexers are added to the informers in all namespaces.
+// See the common indexers, like PodSpecReferenceIndexers() or OwnerUIDIndexers().
+// The indexers are added when the controller is made and they can be added only before the informer is started,
+// otherwise Controller() panics, as it does when there is no informer with the name. The indexers that the informer
+// already has under the same name are kept, so controllers sharing the informer can add the same indexers.
+func (f *Factory) WithIndexers(informerName string, indexers cache.Indexers) *Factory {
+	if f.informerIndexers == nil {
+		f.informerIndexers = map[string]cache.Indexers{}
+	}
+	if f.informerIndexers[informerName] == nil {
+		f.informerIndexers[informerName] = cache.Indexers{}
+	}
+	for name, indexFunc := range indexers {
+		f.informerIndexers[informerName][name] = indexFunc
+	}
+	return f
+}
+
+// WithInformerFactories registers informer factories (or anything with Start(stopCh) method) that are started when the
+// controller runs. This way the caller does not have to remember to start the informers before the controller is run.
+// The factories are started with the ctx set by WithInformersContext(), not with the ctx the controller runs with, so
+// they keep running when the controller stops and can be shared with other controllers.
+func (f *Factory) WithInformerFactories(factories ...InformerStarter) *Factory {
+	f.informerStarters = append(f.informerStarters, factories...)
+	return f
+}
+
+// WithInformersContext sets the ctx the informer factories registered via WithInformerFactories() and the informers
+// built by KubeInformer(), DynamicInformers() and MetadataInformers() are started with. The informers are stopped when
+// the ctx is cancelled. It is required when the factory builds the informers, so they do not outlive the controller,
+// otherwise Controller() panics. Without the factory-built informers, the informers run until the process exits when
+// this is not called.
+func (f *Factory) WithInformersContext(ctx context.Context) *Factory {
+	f.informersCtx = ctx
+	return f
+}
+
+// WithCacheSyncTimeout sets the maximum time the controller waits for the informer caches to sync.
+// If the caches are not synced within the timeout, the controller logs which informers never synced, emits a warning
+// event and exits. Controller.Err() then returns CacheSyncError listing the informers.
+// If this is not called, the controller waits until its ctx is cancelled.
+func (f *Factory) WithCacheSyncTimeout(timeout time.Duration) *Factory {
+	f.cacheSyncTimeout = timeout
 	return f
 }
 
@@ -53,25 +170,240 @@ func (f *Factory) ResyncEvery(interval time.Duration) *Factory {
 	return f
 }
 
+// WithLogger sets the structured logger used by the controller and passed to Sync() via controller context.
+// If this is not called, the messages are logged to klog.
+func (f *Factory) WithLogger(logger Logger) *Factory {
+	f.logger = logger
+	return f
+}
+
+// WithTracerProvider sets the tracer provider used to create a trace span for every Sync() call.
+// The span carry the controller name, queue key, informer event type, retry count and the sync outcome and it is
+// propagated in the ctx passed to Sync(). If this is not called, no spans are created.
+func (f *Factory) WithTracerProvider(provider TracerProvider) *Factory {
+	f.tracerProvider = provider
+	return f
+}
+
+// WithPanicRecovery causes a panic in the Sync() function to be turned into an error with the stack trace.
+// The object is then re-queued with backoff as with any other error, a warning event is emitted and the panic is
+// counted in the sync panics metric. Without this, the panic crashes the whole process.
+func (f *Factory) WithPanicRecovery() *Factory {
+	f.recoverPanics = true
+	return f
+}
+
+// QuarantineAfter causes objects that failed to sync the given number of times in a row to be moved to quarantine
+// instead of being re-queued forever. Quarantined objects are retried only when informer observe a real change to them
+// or when they are released via Controller.ReleaseQuarantined(). Use Controller.QuarantinedItems() to list them.
+// If this is not called, failed objects are always re-queued with backoff.
+func (f *Factory) QuarantineAfter(failures int) *Factory {
+	f.quarantineAt = failures
+	return f
+}
+
+// WithSyncErrorEvents causes sync failures to be recorded as warning events on the involved object.
+// The same message for the same object is recorded at most once per interval and the events for single object are
+// rate-limited, so a controller in a hot error loop does not flood the API server. When a previously failing object
+// is synced successfully, a normal event is recorded.
+// If the recorder is nil, the controller event recorder is used and the events are not attached to the object.
+// Zero interval means 5 minutes.
+func (f *Factory) WithSyncErrorEvents(recorder record.EventRecorder, interval time.Duration) *Factory {
+	f.syncErrorEvents = true
+	f.syncErrorEventsRecorder = recorder
+	f.syncErrorEventsInterval = interval
+	return f
+}
+
+// WithApplyClient sets the client used by Context.Apply() to do server-side apply.
+func (f *Factory) WithApplyClient(client dynamic.Interface) *Factory {
+	f.applyClient = client
+	return f
+}
+
+// WithConflictBackoff sets the backoff of the retries done by Context.RetryOnConflict().
+// If this is not called, retry.DefaultRetry is used.
+func (f *Factory) WithConflictBackoff(backoff wait.Backoff) *Factory {
+	f.conflictBackoff = &backoff
+	return f
+}
+
+// WithWriteObservationTimeout sets how long the controller waits for the informer to observe the objects recorded via
+// controllerContext.RecordWrite() before it syncs the stale informer copy anyway. Defaults to DefaultWriteObservationTimeout.
+func (f *Factory) WithWriteObservationTimeout(timeout time.Duration) *Factory {
+	f.writeTimeout = timeout
+	return f
+}
+
+// WithExpectationsTimeout sets how long the controller waits for the child informers to observe the creations and
+// deletions expected by Sync() before it syncs the owner anyway. Defaults to DefaultExpectationsTimeout.
+func (f *Factory) WithExpectationsTimeout(timeout time.Duration) *Factory {
+	f.expectationsTTL = timeout
+	return f
+}
+
+// WithDebounce collapses the informer events for the same object into single sync. The object is synced when no event
+// was observed for it during the window, but at most maxDelay after the first event, so sustained churn cannot starve
+// it forever. This reduces the number of syncs of aggregate keys, for example owner re-queued on every change of its
+// children during rollout. The retries of failed syncs and objects added to the queue manually are not delayed.
+func (f *Factory) WithDebounce(window, maxDelay time.Duration) *Factory {
+	f.debounceWindow = window
+	f.debounceMaxDelay = maxDelay
+	return f
+}
+
+// WithRateLimiter sets the rate limiter used by the controller queue to delay the retries of failed syncs.
+// If this is not called, workqueue.DefaultControllerRateLimiter() is used.
+func (f *Factory) WithRateLimiter(rateLimiter workqueue.RateLimiter) *Factory {
+	f.rateLimiter = rateLimiter
+	return f
+}
+
+// WithEventRateLimits sets the limits of the events recorded by the controller, see NewRateLimitedRecorder().
+// If this is not called, DefaultEventRateLimits are used.
+func (f *Factory) WithEventRateLimits(limits EventRateLimits) *Factory {
+	f.eventRateLimits = limits
+	return f
+}
+
+// WithoutEventRateLimits disables the rate limiting of the events recorded by the controller, so all events are passed
+// to the given event recorder as they are.
+func (f *Factory) WithoutEventRateLimits() *Factory {
+	f.disableEventRateLimits = true
+	return f
+}
+
+// WithMetricsProvider sets the provider for the controller metrics.
+// If this is not called, no metrics are recorded.
+func (f *Factory) WithMetricsProvider(provider MetricsProvider) *Factory {
+	f.metrics = provider
+	return f
+}
+
 // Controller produce a runnable controller.
 func (f *Factory) Controller(name string, eventRecorder events.Recorder) Controller {
 	if f.sync == nil {
 		panic("Sync() function must be called before making controller")
 	}
+	logger := f.logger
+	if logger == nil {
+		logger = NewKlogLogger()
+	}
+	logger = logger.WithValues(LogKeyController, name)
+	tracerProvider := f.tracerProvider
+	if tracerProvider == nil {
+		tracerProvider = noopTracerProvider{}
+	}
+	metricsProvider := f.metrics
+	if metricsProvider == nil {
+		metricsProvider = noopMetricsProvider{}
+	}
+	recorder := eventRecorder.WithComponentSuffix(name)
+	if !f.disableEventRateLimits {
+		limits := f.eventRateLimits
+		if limits == (EventRateLimits{}) {
+			limits = DefaultEventRateLimits
+		}
+		recorder = NewRateLimitedRecorder(recorder, limits)
+	}
+	shutdownContext, shutdownComplete := context.WithCancel(context.Background())
+	rateLimiter := f.rateLimiter
+	if rateLimiter == nil {
+		rateLimiter = workqueue.DefaultControllerRateLimiter()
+	}
+	newQueue := func() workqueue.RateLimitingInterface {
+		return workqueue.NewNamedRateLimitingQueue(rateLimiter, name)
+	}
+	writeTimeout := f.writeTimeout
+	if writeTimeout == 0 {
+		writeTimeout = DefaultWriteObservationTimeout
+	}
+	expectationsTimeout := f.expectationsTTL
+	if expectationsTimeout == 0 {
+		expectationsTimeout = DefaultExpectationsTimeout
+	}
+	informersCtx := f.informersCtx
+	if informersCtx == nil {
+		if len(f.scopedInformers) > 0 {
+			panic("WithInformersContext() must be set to stop the informers built by KubeInformer(), DynamicInformers() or MetadataInformers()")
+		}
+		informersCtx = context.Background()
+	}
+	namedInformers, informerStarters := f.buildInformers()
+	f.addInformerIndexers(namedInformers)
+	var owners []cache.SharedInformer
+	indexers := map[string][]cache.Indexer{}
+	for _, informer := range namedInformers {
+		if !informer.child && len(informer.cluster) == 0 {
+			owners = append(owners, informer.informer)
+		}
+		if indexInformer, ok := informer.informer.(cache.SharedIndexInformer); ok {
+			indexers[informer.name] = append(indexers[informer.name], indexInformer.GetIndexer())
+			if len(informer.group) > 0 && informer.group != informer.name {
+				indexers[informer.group] = append(indexers[informer.group], indexInformer.GetIndexer())
+			}
+		}
+	}
+	syncTracker := newSyncTracker()
 	c := &baseController{
-		sync:        f.sync,
-		resyncEvery: f.resyncInterval,
+		shutdownContext:   shutdownContext,
+		shutdownComplete:  shutdownComplete,
+		sync:              f.sync,
+		resyncEvery:       f.resyncInterval,
+		informerFactories: informerStarters,
+		informersCtx:      informersCtx,
+		cacheSyncTimeout:  f.cacheSyncTimeout,
+		logger:            logger,
+		tracer:            tracerProvider.Tracer(name),
+		recoverPanics:     f.recoverPanics,
+		metrics:           newControllerMetrics(metricsProvider, name),
+		newQueue:          newQueue,
 		ctx: controllerContext{
 			controllerName: name,
-			eventRecorder:  eventRecorder.WithComponentSuffix(name),
-			queue:          workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), name),
+			eventRecorder:  recorder,
+			queue:          newQueueHolder(newQueue()),
+			logger:         logger,
+			eventTypes:     newEventTypeTracker(),
+			quarantine:     newQuarantine(f.quarantineAt),
+			syncTracker:    syncTracker,
+			applyClient:    f.applyClient,
+			writes:         newWriteTracker(writeTimeout),
+			expectations:   newExpectations(expectationsTimeout),
+			owners:         owners,
+			indexers:       indexers,
 		},
+		syncTracker: syncTracker,
+	}
+
+	conflictBackoff := retry.DefaultRetry
+	if f.conflictBackoff != nil {
+		conflictBackoff = *f.conflictBackoff
 	}
+	c.ctx.conflictRetry = newConflictRetry(conflictBackoff, c.metrics.conflictRetries)
 
-	for i := range f.informers {
-		f.informers[i].AddEventHandler(c.ctx.getEventHandler())
-		c.cachesToSync = append(f.cachesToSync, f.informers[i].HasSynced)
+	if f.debounceWindow > 0 {
+		c.ctx.debouncer = newDebouncer(f.debounceWindow, f.debounceMaxDelay)
 	}
 
+	if f.syncErrorEvents {
+		c.syncEvents = newSyncErrorEvents(f.syncErrorEventsRecorder, c.ctx.Events(), f.syncErrorEventsInterval)
+	}
+
+	c.informers = append(c.informers, namedInformers...)
+	c.registerEventHandlers()
+
 	return c
 }
+
+// namedInformer is an informer with name that identifies it in debug output and errors.
+type namedInformer struct {
+	name string
+	// group is the name shared by the informers built for every namespace by KubeInformer(), DynamicInformers() or
+	// MetadataInformers().
+	group string
+	// cluster is the name of the cluster the informer watches, empty for single cluster controllers.
+	cluster string
+	// child informers only lower the expectations of the owners of the observed objects, see Factory.ChildInformers().
+	child    bool
+	informer cache.SharedInformer
+}
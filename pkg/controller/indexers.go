@@ -0,0 +1,264 @@
+package controller
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Names of the common indexes.
+const (
+	// NamespaceIndex indexes the objects by namespace, the value is the namespace name.
+	NamespaceIndex = cache.NamespaceIndex
+	// OwnerUIDIndex indexes the objects by the UIDs of their owners.
+	OwnerUIDIndex = "ownerUID"
+	// SecretReferenceIndex indexes the objects with pod spec by the "namespace/name" of the secrets they reference.
+	SecretReferenceIndex = "secretReference"
+	// ConfigMapReferenceIndex indexes the objects with pod spec by the "namespace/name" of the config maps they reference.
+	ConfigMapReferenceIndex = "configMapReference"
+)
+
+// NamespaceIndexers returns the indexer of objects by namespace. The typed shared informers have it already.
+func NamespaceIndexers() cache.Indexers {
+	return cache.Indexers{NamespaceIndex: cache.MetaNamespaceIndexFunc}
+}
+
+// OwnerUIDIndexers returns the indexer of objects by the UIDs of their owners, so the children of an object can be
+// listed via ByIndex(informerName, OwnerUIDIndex, string(owner.GetUID())).
+func OwnerUIDIndexers() cache.Indexers {
+	return cache.Indexers{OwnerUIDIndex: func(obj interface{}) ([]string, error) {
+		metaObj, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+		var uids []string
+		for _, ref := range metaObj.GetOwnerReferences() {
+			uids = append(uids, string(ref.UID))
+		}
+		return uids, nil
+	}}
+}
+
+// LabelIndexName returns the name of the index created by LabelIndexers() for the label key.
+func LabelIndexName(key string) string {
+	return "label:" + key
+}
+
+// LabelIndexers returns the indexers of objects by the values of the given label keys. The objects without the label
+// are not indexed. Use ByIndex(informerName, LabelIndexName(key), value) to find the objects with the label value.
+func LabelIndexers(keys ...string) cache.Indexers {
+	indexers := cache.Indexers{}
+	for _, key := range keys {
+		key := key
+		indexers[LabelIndexName(key)] = func(obj interface{}) ([]string, error) {
+			metaObj, err := meta.Accessor(obj)
+			if err != nil {
+				return nil, err
+			}
+			if value, ok := metaObj.GetLabels()[key]; ok {
+				return []string{value}, nil
+			}
+			return nil, nil
+		}
+	}
+	return indexers
+}
+
+// PodSpecReferenceIndexers returns the indexers of objects with pod spec (Pods, Deployments, StatefulSets, DaemonSets,
+// ReplicaSets, Jobs and CronJobs) by the secrets and config maps they reference in volumes, environment variables and
+// image pull secrets. This allows to find all workloads that use the secret when it changes:
+//
+//	deployments, err := controllerContext.ByIndex("deployments", SecretReferenceIndex, "namespace/name")
+func PodSpecReferenceIndexers() cache.Indexers {
+	return cache.Indexers{
+		SecretReferenceIndex: func(obj interface{}) ([]string, error) {
+			return podSpecReferences(obj, func(spec *corev1.PodSpec) sets.String {
+				return podSpecSecretNames(spec)
+			})
+		},
+		ConfigMapReferenceIndex: func(obj interface{}) ([]string, error) {
+			return podSpecReferences(obj, func(spec *corev1.PodSpec) sets.String {
+				return podSpecConfigMapNames(spec)
+			})
+		},
+	}
+}
+
+// podSpecReferences returns the "namespace/name" keys of the objects referenced by the pod spec of the object.
+func podSpecReferences(obj interface{}, names func(spec *corev1.PodSpec) sets.String) ([]string, error) {
+	spec := podSpecOf(obj)
+	if spec == nil {
+		return nil, nil
+	}
+	metaObj, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, name := range names(spec).List() {
+		keys = append(keys, metaObj.GetNamespace()+"/"+name)
+	}
+	return keys, nil
+}
+
+// podSpecOf returns the pod spec of the workload objects or nil for other objects.
+func podSpecOf(obj interface{}) *corev1.PodSpec {
+	switch t := obj.(type) {
+	case *corev1.Pod:
+		return &t.Spec
+	case *appsv1.Deployment:
+		return &t.Spec.Template.Spec
+	case *appsv1.StatefulSet:
+		return &t.Spec.Template.Spec
+	case *appsv1.DaemonSet:
+		return &t.Spec.Template.Spec
+	case *appsv1.ReplicaSet:
+		return &t.Spec.Template.Spec
+	case *batchv1.Job:
+		return &t.Spec.Template.Spec
+	case *batchv1beta1.CronJob:
+		return &t.Spec.JobTemplate.Spec.Template.Spec
+	}
+	return nil
+}
+
+func podSpecContainers(spec *corev1.PodSpec) []corev1.Container {
+	containers := make([]corev1.Container, 0, len(spec.InitContainers)+len(spec.Containers))
+	containers = append(containers, spec.InitContainers...)
+	return append(containers, spec.Containers...)
+}
+
+func podSpecSecretNames(spec *corev1.PodSpec) sets.String {
+	names := sets.NewString()
+	for _, secret := range spec.ImagePullSecrets {
+		names.Insert(secret.Name)
+	}
+	for _, volume := range spec.Volumes {
+		if volume.Secret != nil {
+			names.Insert(volume.Secret.SecretName)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil {
+					names.Insert(source.Secret.Name)
+				}
+			}
+		}
+	}
+	for _, container := range podSpecContainers(spec) {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil {
+				names.Insert(envFrom.SecretRef.Name)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				names.Insert(env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+	names.Delete("")
+	return names
+}
+
+func podSpecConfigMapNames(spec *corev1.PodSpec) sets.String {
+	names := sets.NewString()
+	for _, volume := range spec.Volumes {
+		if volume.ConfigMap != nil {
+			names.Insert(volume.ConfigMap.Name)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					names.Insert(source.ConfigMap.Name)
+				}
+			}
+		}
+	}
+	for _, container := range podSpecContainers(spec) {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				names.Insert(envFrom.ConfigMapRef.Name)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+				names.Insert(env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+		}
+	}
+	names.Delete("")
+	return names
+}
+
+// addIndexers adds the indexers to the informer. The indexers already registered under the same name are kept, so
+// multiple controllers can add the same common indexers to the shared informer.
+func addIndexers(informer cache.SharedIndexInformer, indexers cache.Indexers) error {
+	existing := informer.GetIndexer().GetIndexers()
+	missing := cache.Indexers{}
+	for name, indexFunc := range indexers {
+		if _, ok := existing[name]; !ok {
+			missing[name] = indexFunc
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return informer.AddIndexers(missing)
+}
+
+// addInformerIndexers adds the indexers registered via WithIndexers() to the informers with the name, or to the
+// informers built for every namespace under the name. This panics when there is no such informer or the indexers
+// cannot be added.
+func (f *Factory) addInformerIndexers(namedInformers []namedInformer) {
+	for informerName, indexers := range f.informerIndexers {
+		found := false
+		for _, informer := range namedInformers {
+			if informer.name != informerName && informer.group != informerName {
+				continue
+			}
+			indexInformer, ok := informer.informer.(cache.SharedIndexInformer)
+			if !ok {
+				panic(fmt.Sprintf("unable to add indexers: informer %q does not support indexes", informer.name))
+			}
+			if err := addIndexers(indexInformer, indexers); err != nil {
+				panic(fmt.Sprintf("unable to add indexers to informer %q: %v", informer.name, err))
+			}
+			found = true
+		}
+		if !found {
+			panic(fmt.Sprintf("unable to add indexers: informer %q is not registered", informerName))
+		}
+	}
+}
+
+// ByIndex returns the objects from the cache of the informer with the given name that match the indexed value.
+// The informer names are set via NamedInformer(), the informers added via Informers() are named "informer-N" in the
+// order they were added. The name of the informers built by KubeInformer(), DynamicInformers() and MetadataInformers()
+// returns the objects from all namespaces. The returned objects are copies, so the informer cache is not mutated.
+func (c controllerContext) ByIndex(informerName, indexName, value string) ([]runtime.Object, error) {
+	indexers, ok := c.indexers[informerName]
+	if !ok {
+		return nil, fmt.Errorf("informer %q is not registered or does not support indexes", informerName)
+	}
+	var result []runtime.Object
+	for _, indexer := range indexers {
+		items, err := indexer.ByIndex(indexName, value)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if obj, ok := item.(runtime.Object); ok {
+				result = append(result, obj.DeepCopyObject())
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func TestPodSpecReferenceIndexers(t *testing.T) {
+	podSpec := v1.PodSpec{
+		ImagePullSecrets: []v1.LocalObjectReference{{Name: "pull-secret"}},
+		Volumes: []v1.Volume{
+			{Name: "a", VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: "volume-secret"}}},
+			{Name: "b", VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: "volume-config"}}}},
+			{Name: "c", VolumeSource: v1.VolumeSource{Projected: &v1.ProjectedVolumeSource{Sources: []v1.VolumeProjection{
+				{Secret: &v1.SecretProjection{LocalObjectReference: v1.LocalObjectReference{Name: "projected-secret"}}},
+				{ConfigMap: &v1.ConfigMapProjection{LocalObjectReference: v1.LocalObjectReference{Name: "projected-config"}}},
+			}}}},
+		},
+		InitContainers: []v1.Container{{
+			EnvFrom: []v1.EnvFromSource{{SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "init-secret"}}}},
+		}},
+		Containers: []v1.Container{{
+			EnvFrom: []v1.EnvFromSource{{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "env-config"}}}},
+			Env: []v1.EnvVar{
+				{Name: "A", ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "volume-secret"}, Key: "a"}}},
+				{Name: "B", ValueFrom: &v1.EnvVarSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "key-config"}, Key: "b"}}},
+			},
+		}},
+	}
+	objectMeta := meta.ObjectMeta{Name: "workload", Namespace: "test"}
+
+	indexers := PodSpecReferenceIndexers()
+	for _, obj := range []interface{}{
+		&appsv1.Deployment{ObjectMeta: objectMeta, Spec: appsv1.DeploymentSpec{Template: v1.PodTemplateSpec{Spec: podSpec}}},
+		&batchv1beta1.CronJob{ObjectMeta: objectMeta, Spec: batchv1beta1.CronJobSpec{JobTemplate: batchv1beta1.JobTemplateSpec{
+			Spec: batchv1.JobSpec{Template: v1.PodTemplateSpec{Spec: podSpec}},
+		}}},
+		&v1.Pod{ObjectMeta: objectMeta, Spec: podSpec},
+	} {
+		secrets, err := indexers[SecretReferenceIndex](obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := []string{"test/init-secret", "test/projected-secret", "test/pull-secret", "test/volume-secret"}; !reflect.DeepEqual(secrets, expected) {
+			t.Errorf("%T: expected secrets %v, got %v", obj, expected, secrets)
+		}
+		configMaps, err := indexers[ConfigMapReferenceIndex](obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := []string{"test/env-config", "test/key-config", "test/projected-config", "test/volume-config"}; !reflect.DeepEqual(configMaps, expected) {
+			t.Errorf("%T: expected config maps %v, got %v", obj, expected, configMaps)
+		}
+	}
+
+	if secrets, err := indexers[SecretReferenceIndex](makeFakeSecret()); err != nil || len(secrets) != 0 {
+		t.Errorf("expected objects without pod spec to not be indexed, got %v: %v", secrets, err)
+	}
+}
+
+func TestLabelAndOwnerIndexers(t *testing.T) {
+	secret := makeFakeSecret()
+	secret.Labels = map[string]string{"app": "web"}
+	secret.OwnerReferences = []meta.OwnerReference{{UID: "a"}, {UID: "b"}}
+
+	values, err := LabelIndexers("app", "tier")[LabelIndexName("app")](secret)
+	if err != nil || !reflect.DeepEqual(values, []string{"web"}) {
+		t.Errorf("expected label value to be indexed, got %v: %v", values, err)
+	}
+	values, err = LabelIndexers("app", "tier")[LabelIndexName("tier")](secret)
+	if err != nil || len(values) != 0 {
+		t.Errorf("expected object without label to not be indexed, got %v: %v", values, err)
+	}
+	values, err = OwnerUIDIndexers()[OwnerUIDIndex](secret)
+	if err != nil || !reflect.DeepEqual(values, []string{"a", "b"}) {
+		t.Errorf("expected owner UIDs to be indexed, got %v: %v", values, err)
+	}
+}
+
+func TestByIndex(t *testing.T) {
+	secret := makeFakeSecret()
+	deployment := func(name, secretName string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: meta.ObjectMeta{Name: name, Namespace: "test"},
+			Spec: appsv1.DeploymentSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{
+				Volumes: []v1.Volume{{Name: "secret", VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: secretName}}}},
+			}}},
+		}
+	}
+	kubeClient := fake.NewSimpleClientset(secret, deployment("a", "test-secret"), deployment("b", "other-secret"), deployment("c", "test-secret"))
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	deploymentInformer := kubeInformers.Apps().V1().Deployments().Informer()
+	type result struct {
+		names              []string
+		unknownInformerErr error
+		cacheMutated       bool
+	}
+	found := make(chan result, 1)
+	controller := NewFactory().
+		Informers(kubeInformers.Core().V1().Secrets().Informer()).
+		NamedInformer("deployments", deploymentInformer).
+		WithIndexers("deployments", PodSpecReferenceIndexers()).
+		WithIndexers("deployments", NamespaceIndexers()).
+		Sync(func(ctx context.Context, controllerContext Context) error {
+			// the deployments are synced too
+			if _, ok := controllerContext.GetQueueObject().(*v1.Secret); !ok {
+				return nil
+			}
+			_, unknownInformerErr := controllerContext.ByIndex("unknown", SecretReferenceIndex, "test/test-secret")
+			objs, err := controllerContext.ByIndex("deployments", SecretReferenceIndex, controllerContext.GetObjectMeta().GetNamespace()+"/"+controllerContext.GetObjectMeta().GetName())
+			if err != nil {
+				return err
+			}
+			var names []string
+			for _, obj := range objs {
+				names = append(names, obj.(*appsv1.Deployment).Name)
+				obj.(*appsv1.Deployment).Labels = map[string]string{"mutated": "true"}
+			}
+			sort.Strings(names)
+			cached, _, _ := deploymentInformer.GetIndexer().GetByKey("test/a")
+			select {
+			case found <- result{names: names, unknownInformerErr: unknownInformerErr, cacheMutated: len(cached.(*appsv1.Deployment).Labels) > 0}:
+			default:
+			}
+			return nil
+		}).Controller("IndexController", events.NewInMemoryRecorder("index-controller"))
+
+	go kubeInformers.Start(ctx.Done())
+	go controller.Run(ctx, 1)
+
+	select {
+	case r := <-found:
+		if !reflect.DeepEqual(r.names, []string{"a", "c"}) {
+			t.Errorf("expected deployments referencing the secret, got %v", r.names)
+		}
+		if r.unknownInformerErr == nil {
+			t.Errorf("expected unknown informer to return error")
+		}
+		if r.cacheMutated {
+			t.Errorf("expected copies of the cached objects to be returned")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("test timeout")
+	}
+
+	// other controller sharing the informer can add the same indexers
+	NewFactory().NamedInformer("deployments", deploymentInformer).WithIndexers("deployments", PodSpecReferenceIndexers()).
+		Sync(func(ctx context.Context, controllerContext Context) error { return nil }).
+		Controller("OtherIndexController", events.NewInMemoryRecorder("other-index-controller"))
+
+	for name, informerName := range map[string]string{
+		"started informer": "deployments",
+		"unknown informer": "unknown",
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected adding indexers to %s to panic", name)
+				}
+			}()
+			NewFactory().NamedInformer("deployments", deploymentInformer).WithIndexers(informerName, LabelIndexers("app")).
+				Sync(func(ctx context.Context, controllerContext Context) error { return nil }).
+				Controller("PanicIndexController", events.NewInMemoryRecorder("panic-index-controller"))
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// InformerStarter is anything that can start informers, like the informers.SharedInformerFactory.
+type InformerStarter interface {
+	// Start initializes all requested informers. It must be non-blocking.
+	Start(stopCh <-chan struct{})
+}
+
+// informerRunner starts the informer built by the factory, starting it again is no-op like with the informer factories.
+type informerRunner struct {
+	informer cache.SharedInformer
+	once     sync.Once
+}
+
+func (r *informerRunner) Start(stopCh <-chan struct{}) {
+	r.once.Do(func() {
+		go r.informer.Run(stopCh)
+	})
+}
+
+// informerFactorySyncer is implemented by generated informer factories and allows to report which informer types
+// failed to sync.
+type informerFactorySyncer interface {
+	WaitForCacheSync(stopCh <-chan struct{}) map[reflect.Type]bool
+}
+
+// CacheSyncError is returned when informer caches failed to sync before the cache sync timeout.
+type CacheSyncError struct {
+	// Informers lists the names of informers that did not sync.
+	Informers []string
+	// Timeout is the cache sync timeout.
+	Timeout time.Duration
+}
+
+func (e *CacheSyncError) Error() string {
+	return fmt.Sprintf("caches not synced after %s: %s", e.Timeout, strings.Join(e.Informers, ", "))
+}
+
+// startInformers starts all informer factories registered in factory. The informers are stopped when the informers
+// ctx set in factory is done, so they survive the controller restarts. Starting the factory again is no-op.
+func (c *baseController) startInformers() {
+	for i := range c.informerFactories {
+		c.informerFactories[i].Start(c.informersCtx.Done())
+	}
+}
+
+// waitForCacheSync waits for the informer caches to sync. When the cache sync timeout is set and the caches are not
+// synced before it, CacheSyncError listing the informers that never synced is returned.
+func (c *baseController) waitForCacheSync(ctx context.Context) error {
+	waitCtx := ctx
+	if c.cacheSyncTimeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, c.cacheSyncTimeout)
+		defer cancel()
+	}
+
+	cachesToSync := make([]cache.InformerSynced, 0, len(c.informers))
+	for i := range c.informers {
+		cachesToSync = append(cachesToSync, c.informers[i].informer.HasSynced)
+	}
+	if cache.WaitForCacheSync(waitCtx.Done(), cachesToSync...) {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return &CacheSyncError{Informers: c.unsyncedInformers(), Timeout: c.cacheSyncTimeout}
+}
+
+// unsyncedInformers returns names of registered informers and types of informer factory informers that are not synced.
+func (c *baseController) unsyncedInformers() []string {
+	var result []string
+	for i := range c.informers {
+		if !c.informers[i].informer.HasSynced() {
+			result = append(result, c.informers[i].name)
+		}
+	}
+
+	// closed channel makes the factory report the current state without waiting
+	closed := make(chan struct{})
+	close(closed)
+	for i := range c.informerFactories {
+		syncer, ok := c.informerFactories[i].(informerFactorySyncer)
+		if !ok {
+			continue
+		}
+		for informerType, synced := range syncer.WaitForCacheSync(closed) {
+			if !synced {
+				result = append(result, informerType.String())
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func TestInformerFactoriesStartedByController(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(makeFakeSecret())
+
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	controllerSynced := make(chan struct{})
+	controller := NewFactory().WithInformerFactories(kubeInformers).Informers(kubeInformers.Core().V1().Secrets().Informer()).Sync(func(ctx context.Context, controllerContext Context) error {
+		defer close(controllerSynced)
+		return nil
+	}).Controller("StartingController", events.NewInMemoryRecorder("starting-controller"))
+
+	// the informers are not started here, the controller is expected to start them
+	go controller.Run(ctx, 1)
+
+	select {
+	case <-controllerSynced:
+	case <-time.After(30 * time.Second):
+		t.Fatal("test timeout")
+	}
+	if controller.Err() != nil {
+		t.Errorf("expected no error, got %v", controller.Err())
+	}
+}
+
+func TestSharedInformerFactoriesOutliveController(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(makeFakeSecret())
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+	informersCtx, cancelInformers := context.WithCancel(context.TODO())
+	defer cancelInformers()
+
+	newController := func(name string, synced chan<- string) Controller {
+		return NewFactory().WithInformerFactories(kubeInformers).WithInformersContext(informersCtx).
+			Informers(kubeInformers.Core().V1().Secrets().Informer()).
+			Sync(func(ctx context.Context, controllerContext Context) error {
+				synced <- controllerContext.GetObjectMeta().GetLabels()["version"]
+				return nil
+			}).Controller(name, events.NewInMemoryRecorder(name))
+	}
+	firstSynced, secondSynced := make(chan string, 10), make(chan string, 10)
+	first, second := newController("FirstController", firstSynced), newController("SecondController", secondSynced)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	if err := first.Start(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Start(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	expectSync := func(synced <-chan string, version string) {
+		for {
+			select {
+			case got := <-synced:
+				if got == version {
+					return
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatalf("expected version %q to be synced", version)
+			}
+		}
+	}
+	expectSync(firstSynced, "")
+	expectSync(secondSynced, "")
+
+	// stopping the first controller must not stop the informers shared with the second one
+	first.Stop(ErrLeaderLost)
+	first.Wait()
+	secret := makeFakeSecret()
+	secret.Labels = map[string]string{"version": "2"}
+	if _, err := kubeClient.CoreV1().Secrets("test").Update(secret); err != nil {
+		t.Fatal(err)
+	}
+	expectSync(secondSynced, "2")
+}
+
+func TestCacheSyncTimeout(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	kubeClient.PrependReactor("list", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("secrets is forbidden")
+	})
+
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	logger := newFakeLogger()
+	recorder := events.NewInMemoryRecorder("timeout-controller")
+	controller := NewFactory().
+		WithInformerFactories(kubeInformers).
+		WithCacheSyncTimeout(500*time.Millisecond).
+		WithLogger(logger).
+		NamedInformer("secrets", kubeInformers.Core().V1().Secrets().Informer()).
+		Sync(func(ctx context.Context, controllerContext Context) error {
+			t.Errorf("unexpected sync")
+			return nil
+		}).Controller("TimeoutController", recorder)
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		controller.Run(ctx, 1)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(30 * time.Second):
+		t.Fatal("expected controller to exit after cache sync timeout")
+	}
+
+	var syncErr *CacheSyncError
+	if !errors.As(controller.Err(), &syncErr) || strings.Join(syncErr.Informers, ",") != "*v1.Secret,secrets" {
+		t.Errorf("expected CacheSyncError for secrets informers, got %v", controller.Err())
+	}
+	warnings := 0
+	for _, e := range recorder.Events() {
+		if e.Reason == "CacheSyncFailed" {
+			warnings++
+		}
+	}
+	if warnings != 1 {
+		t.Errorf("expected CacheSyncFailed warning event, got %#v", recorder.Events())
+	}
+
+	found := false
+	for _, m := range logger.Messages() {
+		if strings.HasPrefix(m, "Failed to wait for caches to sync") && strings.Contains(m, `err="caches not synced after 500ms: *v1.Secret, secrets"`) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected cache sync failure to be logged with informer names, got %#v", logger.Messages())
+	}
+}
+
+func TestMetadataInformers(t *testing.T) {
+	secret := &meta.PartialObjectMetadata{
+		TypeMeta:   meta.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: meta.ObjectMeta{Name: "test-secret", Namespace: "test", Labels: map[string]string{"app": "web"}},
+	}
+	scheme := runtime.NewScheme()
+	if err := meta.AddMetaToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	metadataClient := metadatafake.NewSimpleMetadataClient(scheme, secret)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	secrets := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	type synced struct {
+		obj  runtime.Object
+		meta meta.Object
+	}
+	syncedObjects := make(chan synced, 1)
+	controller := NewFactory().
+		MetadataInformers(metadataClient, 1*time.Minute, secrets).
+		ForNamespaces("test").
+		WithInformersContext(ctx).
+		WithIndexers("secrets", LabelIndexers("app")).
+		Sync(func(ctx context.Context, controllerContext Context) error {
+			objs, err := controllerContext.ByIndex("secrets", LabelIndexName("app"), "web")
+			if err != nil {
+				return err
+			}
+			if len(objs) != 1 {
+				return fmt.Errorf("expected the secret to be found by informer name, got %v", objs)
+			}
+			select {
+			case syncedObjects <- synced{obj: controllerContext.GetQueueObject(), meta: controllerContext.GetObjectMeta()}:
+			default:
+			}
+			return nil
+		}).Controller("MetadataController", events.NewInMemoryRecorder("metadata-controller"))
+	go controller.Run(ctx, 1)
+
+	select {
+	case s := <-syncedObjects:
+		if _, ok := s.obj.(*meta.PartialObjectMetadata); !ok {
+			t.Errorf("expected metadata-only object, got %T", s.obj)
+		}
+		if s.meta == nil || s.meta.GetName() != "test-secret" || s.meta.GetLabels()["app"] != "web" {
+			t.Errorf("expected object metadata of the secret, got %#v", s.meta)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("test timeout")
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/util/workqueue"
 
 	"github.com/openshift/library-go/pkg/operator/events"
@@ -17,11 +19,46 @@ type Controller interface {
 	// Run runs the controller and blocks until the controller is finished.
 	// Number of workers can be specified via workers parameter.
 	// Note that having more than one worker usually means handing parallelization of Sync().
+	// Calling Run on a controller that is still running panics.
+	// A stopped controller can be run again. It gets a fresh queue and the current informer state is re-queued.
 	Run(ctx context.Context, workers int)
 
+	// Start starts the controller without blocking. It waits only for the informer caches to sync and returns an error
+	// if the controller is already running or the caches failed to sync.
+	// Use Wait() to wait for the controller to finish. A stopped controller can be started again the same way as with Run().
+	// Unlike Run(), a panic in a worker stops the controller and is reported by Wait() instead of crashing the process.
+	Start(ctx context.Context, workers int) error
+
+	// Wait blocks until the controller is finished and returns the reason it stopped: CacheSyncError when caches
+	// failed to sync, ErrLeaderLost (or any other error passed to Stop()), SyncPanicError when a worker panicked, or nil
+	// when the controller was stopped cleanly by cancelling the ctx.
+	Wait() error
+
+	// Stop requests the controller shutdown with the given reason that is then returned by Wait() and Err().
+	// Pass ErrLeaderLost when the leader election was lost. Stop has no effect when the controller is not running.
+	Stop(reason error)
+
 	// ShutdownContext can be used to observe the finished shutdown of all controller workers and controller itself.
+	// It is valid before the controller is started. Every restart of the controller gets a new shutdown context.
 	// Example: <-controller.ShutdownContext().Done()
 	ShutdownContext() context.Context
+
+	// Err returns the error that caused the controller to stop, for example when the informer caches failed to sync
+	// before the cache sync timeout (CacheSyncError). It returns nil while the controller runs or when it was stopped
+	// by cancelling the ctx.
+	Err() error
+
+	// QuarantinedItems lists the objects that failed to sync too many times in a row together with their last error.
+	// Quarantined objects are not retried until informer observe a real change to them or they are released.
+	QuarantinedItems() []QuarantinedItem
+
+	// ReleaseQuarantined removes the object of the given kind with the "namespace/name" key from quarantine and re-queue
+	// it. The kind is the QuarantinedItem.Kind. Returns false if the object was not quarantined.
+	ReleaseQuarantined(kind, key string) bool
+
+	// DebugInfo returns snapshot of the controller internal state (queue depth, in-flight syncs, recent errors, worker
+	// states and informers sync status). Use DebugHandler() to expose it via HTTP.
+	DebugInfo() DebugInfo
 }
 
 // Context interface represents a context given to the Sync() function where the main controller logic happen.
@@ -39,9 +76,48 @@ type Context interface {
 	// It is safe to mutate this object inside Sync().
 	GetQueueObject() runtime.Object
 
+	// ClusterName provides the name of the cluster the currently synced object came from, when the informer was added
+	// via ClusterInformers(). This can be used to pick the client for the cluster. It is empty for other informers.
+	ClusterName() string
+
 	// Events provide access to event recorder.
 	Events() events.Recorder
 
 	// ControllerName gives name of the controller.
 	ControllerName() string
+
+	// Apply does server-side apply of the given object with the controller name as the field manager and reports whether
+	// the object was created or changed. The event is recorded only when the object changed. Without force, conflicts
+	// with other field managers are returned as conflict errors. The client must be set via Factory.WithApplyClient().
+	Apply(resource schema.GroupVersionResource, obj *unstructured.Unstructured, options ApplyOptions) (*unstructured.Unstructured, bool, error)
+
+	// RetryOnConflict resolves update conflicts within the same Sync() call instead of failing the sync and waiting for
+	// the rate-limited re-queue. The get function reads the object from the informer lister or the live client and the
+	// update function mutates and updates the deep copy of it. When the update fails with conflict, both are called again
+	// with backoff set via Factory.WithConflictBackoff(). The retries are counted by the conflict retries metric.
+	RetryOnConflict(get func() (runtime.Object, error), update func(obj runtime.Object) error) error
+
+	// RecordWrite records the object returned by create or update made in Sync(), so the controller does not sync the
+	// stale informer copies of it again. The syncs of the object are skipped until the informer observes the written
+	// version or the timeout set via Factory.WithWriteObservationTimeout() expires. Apply() records its writes itself.
+	RecordWrite(obj runtime.Object)
+
+	// ExpectCreations and ExpectDeletions record how many child objects the Sync() is going to create or delete. The
+	// synced object is not synced again until the child informers (see Factory.ChildInformers()) observe them or the
+	// timeout set via Factory.WithExpectationsTimeout() expires. Call them before the children are created or deleted.
+	ExpectCreations(count int)
+	ExpectDeletions(count int)
+
+	// LowerExpectations lowers the expectations of the synced object for the creates or deletes that failed.
+	LowerExpectations(creations, deletions int)
+
+	// ByIndex returns the objects from the cache of the named informer that match the indexed value, see
+	// Factory.WithIndexers(). The informers added via Informers() are named "informer-N" in the order they were added.
+	// The name of the informers built for every namespace returns the objects from all namespaces.
+	// The returned objects are copies of the cached objects.
+	ByIndex(informerName, indexName, value string) ([]runtime.Object, error)
+
+	// Logger provides a structured logger pre-populated with the controller name, worker id, queue key, resource kind
+	// and unique sync id, so all messages logged during a single Sync() can be correlated.
+	Logger() Logger
 }
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+// Logger represents a structured (logr-style) logger.
+// Messages are constant strings and the variable information is passed as key/value pairs, which allows log
+// aggregation to follow a single Sync() by its fields instead of parsing the message.
+type Logger interface {
+	// Info logs a non-error message with the given key/value pairs as context.
+	Info(msg string, keysAndValues ...interface{})
+
+	// Error logs an error with the given message and key/value pairs as context.
+	Error(err error, msg string, keysAndValues ...interface{})
+
+	// V returns a logger for a specific verbosity level. Higher levels are less important.
+	V(level int) Logger
+
+	// WithValues returns a logger that adds the key/value pairs to every message it logs.
+	WithValues(keysAndValues ...interface{}) Logger
+
+	// WithName returns a logger that appends the name segment to the logger name.
+	WithName(name string) Logger
+}
+
+// Keys used by the framework for the structured log fields.
+const (
+	LogKeyController = "controller"
+	LogKeyWorker     = "worker"
+	LogKeyQueueKey   = "key"
+	LogKeyKind       = "kind"
+	LogKeySyncID     = "syncID"
+	LogKeyCluster    = "cluster"
+)
+
+// klogLogger is the default Logger implementation that writes to klog.
+type klogLogger struct {
+	name   string
+	level  int
+	values []interface{}
+}
+
+var _ Logger = klogLogger{}
+
+// NewKlogLogger returns a structured logger that writes the messages into klog.
+// The key/value pairs are rendered as key="value" after the message.
+func NewKlogLogger() Logger {
+	return klogLogger{}
+}
+
+func (l klogLogger) Info(msg string, keysAndValues ...interface{}) {
+	if !klog.V(klog.Level(l.level)) {
+		return
+	}
+	klog.InfoDepth(1, l.format(msg, nil, keysAndValues))
+}
+
+func (l klogLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	klog.ErrorDepth(1, l.format(msg, err, keysAndValues))
+}
+
+func (l klogLogger) V(level int) Logger {
+	l.level += level
+	return l
+}
+
+func (l klogLogger) WithValues(keysAndValues ...interface{}) Logger {
+	values := make([]interface{}, 0, len(l.values)+len(keysAndValues))
+	values = append(values, l.values...)
+	l.values = append(values, keysAndValues...)
+	return l
+}
+
+func (l klogLogger) WithName(name string) Logger {
+	if len(l.name) > 0 {
+		l.name = l.name + "/" + name
+	} else {
+		l.name = name
+	}
+	return l
+}
+
+func (l klogLogger) format(msg string, err error, keysAndValues []interface{}) string {
+	b := &bytes.Buffer{}
+	if len(l.name) > 0 {
+		b.WriteString(l.name)
+		b.WriteString(": ")
+	}
+	b.WriteString(msg)
+	if err != nil {
+		writeKeyValues(b, "err", err.Error())
+	}
+	writeKeyValues(b, l.values...)
+	writeKeyValues(b, keysAndValues...)
+	return b.String()
+}
+
+func writeKeyValues(b *bytes.Buffer, keysAndValues ...interface{}) {
+	for i := 0; i < len(keysAndValues); i += 2 {
+		var value interface{} = "(MISSING)"
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		b.WriteString(" ")
+		b.WriteString(fmt.Sprintf("%v", keysAndValues[i]))
+		b.WriteString("=")
+		b.WriteString(fmt.Sprintf("%q", strings.TrimSpace(fmt.Sprintf("%+v", value))))
+	}
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// fakeLogger records all messages with their key/value pairs.
+type fakeLogger struct {
+	values   []interface{}
+	messages *[]string
+	lock     *sync.Mutex
+}
+
+func newFakeLogger() fakeLogger {
+	return fakeLogger{messages: &[]string{}, lock: &sync.Mutex{}}
+}
+
+func (l fakeLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.log(msg, keysAndValues)
+}
+
+func (l fakeLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.log(msg, append([]interface{}{"err", err}, keysAndValues...))
+}
+
+func (l fakeLogger) V(int) Logger {
+	return l
+}
+
+func (l fakeLogger) WithValues(keysAndValues ...interface{}) Logger {
+	l.values = append(append([]interface{}{}, l.values...), keysAndValues...)
+	return l
+}
+
+func (l fakeLogger) WithName(string) Logger {
+	return l
+}
+
+func (l fakeLogger) log(msg string, keysAndValues []interface{}) {
+	b := &bytes.Buffer{}
+	b.WriteString(msg)
+	writeKeyValues(b, l.values...)
+	writeKeyValues(b, keysAndValues...)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	*l.messages = append(*l.messages, b.String())
+}
+
+func (l fakeLogger) Messages() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]string{}, *l.messages...)
+}
+
+func (l fakeLogger) valueFor(key string) string {
+	for i := 0; i+1 < len(l.values); i += 2 {
+		if l.values[i] == key {
+			return fmt.Sprintf("%v", l.values[i+1])
+		}
+	}
+	return ""
+}
+
+func TestSyncLoggerFields(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(makeFakeSecret())
+
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	go kubeInformers.Start(ctx.Done())
+
+	logger := newFakeLogger()
+	syncLogger := make(chan fakeLogger, 1)
+	controller := NewFactory().Informers(kubeInformers.Core().V1().Secrets().Informer()).WithLogger(logger).Sync(func(ctx context.Context, controllerContext Context) error {
+		controllerContext.Logger().Info("Sync called")
+		select {
+		case syncLogger <- controllerContext.Logger().(fakeLogger):
+		default:
+		}
+		return nil
+	}).Controller("LoggerController", events.NewInMemoryRecorder("logger-controller"))
+
+	go controller.Run(ctx, 1)
+
+	var l fakeLogger
+	select {
+	case l = <-syncLogger:
+	case <-time.After(30 * time.Second):
+		t.Fatal("test timeout")
+	}
+
+	expected := map[string]string{
+		LogKeyController: "LoggerController",
+		LogKeyWorker:     "1",
+		LogKeyQueueKey:   "test/test-secret",
+		LogKeyKind:       "Secret",
+	}
+	for key, value := range expected {
+		if got := l.valueFor(key); got != value {
+			t.Errorf("expected %q to be %q, got %q", key, value, got)
+		}
+	}
+	if len(l.valueFor(LogKeySyncID)) == 0 {
+		t.Errorf("expected %q to be set", LogKeySyncID)
+	}
+
+	found := false
+	for _, m := range logger.Messages() {
+		if m == `Starting controller controller="LoggerController"` {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected structured start message, got %#v", logger.Messages())
+	}
+}
+
+func TestKlogLoggerFormat(t *testing.T) {
+	l := NewKlogLogger().WithName("test").WithValues(LogKeyController, "foo").(klogLogger)
+	if got, expected := l.format("Sync failed", fmt.Errorf("boom"), []interface{}{LogKeyQueueKey, "ns/name"}), `test: Sync failed err="boom" controller="foo" key="ns/name"`; got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
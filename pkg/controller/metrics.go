@@ -0,0 +1,51 @@
+package controller
+
+// CounterMetric represents a single numerical value that only ever goes up.
+type CounterMetric interface {
+	Inc()
+}
+
+// MetricsProvider generates various metrics used by the controller.
+// It follows the workqueue.MetricsProvider pattern, so the metrics can be backed by Prometheus or any other library.
+type MetricsProvider interface {
+	// NewSyncPanicsMetric returns counter of Sync() calls that panicked for the given controller.
+	NewSyncPanicsMetric(name string) CounterMetric
+	// NewSyncFailuresMetric returns counter of Sync() calls that returned an error for the given controller.
+	NewSyncFailuresMetric(name string) CounterMetric
+	// NewConflictRetriesMetric returns counter of updates retried after conflict via Context.RetryOnConflict() for the
+	// given controller. The conflicts resolved by the retry are not counted as sync failures.
+	NewConflictRetriesMetric(name string) CounterMetric
+}
+
+type noopMetric struct{}
+
+func (noopMetric) Inc() {}
+
+type noopMetricsProvider struct{}
+
+func (noopMetricsProvider) NewSyncPanicsMetric(string) CounterMetric {
+	return noopMetric{}
+}
+
+func (noopMetricsProvider) NewSyncFailuresMetric(string) CounterMetric {
+	return noopMetric{}
+}
+
+func (noopMetricsProvider) NewConflictRetriesMetric(string) CounterMetric {
+	return noopMetric{}
+}
+
+// controllerMetrics holds all metrics for single controller.
+type controllerMetrics struct {
+	syncPanics      CounterMetric
+	syncFailures    CounterMetric
+	conflictRetries CounterMetric
+}
+
+func newControllerMetrics(provider MetricsProvider, name string) controllerMetrics {
+	return controllerMetrics{
+		syncPanics:      provider.NewSyncPanicsMetric(name),
+		syncFailures:    provider.NewSyncFailuresMetric(name),
+		conflictRetries: provider.NewConflictRetriesMetric(name),
+	}
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// QuarantinedItem describes an object that failed to sync too many times in a row.
+// Quarantined objects are not retried until the informer observes a real change to them or they are released manually.
+type QuarantinedItem struct {
+	// Key is the "namespace/name" queue key of the object.
+	Key string
+	// Kind is the kind of the object, qualified with the group for objects with TypeMeta set, for example
+	// "Deployment.apps". Objects from typed informers have the Go type name, for example "Secret".
+	Kind string
+	// Failures is the number of consecutive sync failures.
+	Failures int
+	// LastError is the error returned by the last failed sync.
+	LastError error
+	// Since is the time when the object was quarantined.
+	Since time.Time
+
+	// object is the last object that failed to sync, it is re-queued on manual release.
+	object runtime.Object
+}
+
+// quarantine tracks consecutive sync failures per object and holds the objects that reached the failure threshold.
+// Zero threshold disables the quarantine.
+type quarantine struct {
+	threshold   int
+	failures    map[string]int
+	quarantined map[string]*QuarantinedItem
+	lock        sync.Mutex
+}
+
+func newQuarantine(threshold int) *quarantine {
+	return &quarantine{
+		threshold:   threshold,
+		failures:    map[string]int{},
+		quarantined: map[string]*QuarantinedItem{},
+	}
+}
+
+// failed records the sync failure for the object. It returns the number of consecutive failures and true when the
+// object was moved to quarantine.
+func (q *quarantine) failed(obj runtime.Object, err error) (int, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	key := objectKeyFor(obj)
+	q.failures[key]++
+	failures := q.failures[key]
+	if q.threshold <= 0 || failures < q.threshold {
+		return failures, false
+	}
+	q.quarantined[key] = &QuarantinedItem{
+		Key:       queueKeyFor(obj),
+		Kind:      objectKind(obj),
+		Failures:  failures,
+		LastError: err,
+		Since:     time.Now(),
+		object:    obj,
+	}
+	return failures, true
+}
+
+// succeeded resets the failure count for the object.
+func (q *quarantine) succeeded(obj runtime.Object) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delete(q.failures, objectKeyFor(obj))
+}
+
+// forget removes the object from quarantine and resets its failure count when the object was deleted.
+func (q *quarantine) forget(obj runtime.Object) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	key := objectKeyFor(obj)
+	delete(q.quarantined, key)
+	delete(q.failures, key)
+}
+
+func (q *quarantine) isQuarantined(obj runtime.Object) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	_, ok := q.quarantined[objectKeyFor(obj)]
+	return ok
+}
+
+// release removes the object of the kind with the "namespace/name" key from quarantine and resets its failure count.
+// The last object that failed to sync is returned, or nil if the object was not quarantined.
+func (q *quarantine) release(kind, key string) runtime.Object {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	key = kindKey(kind, key)
+	item, ok := q.quarantined[key]
+	if !ok {
+		return nil
+	}
+	delete(q.quarantined, key)
+	delete(q.failures, key)
+	return item.object
+}
+
+// list returns copy of all quarantined items sorted by kind and key.
+func (q *quarantine) list() []QuarantinedItem {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	result := make([]QuarantinedItem, 0, len(q.quarantined))
+	for _, item := range q.quarantined {
+		result = append(result, *item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Kind != result[j].Kind {
+			return result[i].Kind < result[j].Kind
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func TestQuarantine(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(makeFakeSecret())
+
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	go kubeInformers.Start(ctx.Done())
+
+	var syncCount int64
+	controller := NewFactory().Informers(kubeInformers.Core().V1().Secrets().Informer()).QuarantineAfter(3).Sync(func(ctx context.Context, controllerContext Context) error {
+		atomic.AddInt64(&syncCount, 1)
+		return fmt.Errorf("always failing")
+	}).Controller("QuarantineController", events.NewInMemoryRecorder("quarantine-controller"))
+
+	go controller.Run(ctx, 1)
+
+	waitForQuarantine := func(expectedSyncs int64) {
+		if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+			return len(controller.QuarantinedItems()) == 1 && atomic.LoadInt64(&syncCount) == expectedSyncs, nil
+		}); err != nil {
+			t.Fatalf("expected object to be quarantined after %d syncs, got %d syncs and %#v", expectedSyncs, atomic.LoadInt64(&syncCount), controller.QuarantinedItems())
+		}
+	}
+
+	waitForQuarantine(3)
+	item := controller.QuarantinedItems()[0]
+	if item.Key != "test/test-secret" || item.Kind != "Secret" || item.Failures != 3 || item.LastError == nil || item.LastError.Error() != "always failing" {
+		t.Errorf("unexpected quarantined item: %#v", item)
+	}
+
+	// make sure the object is not retried
+	time.Sleep(500 * time.Millisecond)
+	if count := atomic.LoadInt64(&syncCount); count != 3 {
+		t.Fatalf("expected quarantined object to not be synced, got %d syncs", count)
+	}
+
+	if !controller.ReleaseQuarantined("Secret", "test/test-secret") {
+		t.Fatalf("expected quarantined object to be released")
+	}
+	if controller.ReleaseQuarantined("Secret", "test/test-secret") {
+		t.Fatalf("expected second release to be no-op")
+	}
+	waitForQuarantine(6)
+
+	// a real change to the object releases it from quarantine
+	secret := makeFakeSecret()
+	secret.Data["test"] = []byte("changed")
+	secret.ResourceVersion = "2"
+	if _, err := kubeClient.CoreV1().Secrets("test").Update(secret); err != nil {
+		t.Fatalf("failed to update fake secret: %v", err)
+	}
+	waitForQuarantine(9)
+}
+
+func TestQuarantineKeyedByKind(t *testing.T) {
+	secret := &v1.Secret{ObjectMeta: meta.ObjectMeta{Namespace: "test", Name: "foo"}}
+	configMap := &v1.ConfigMap{ObjectMeta: meta.ObjectMeta{Namespace: "test", Name: "foo"}}
+
+	q := newQuarantine(1)
+	q.failed(secret, fmt.Errorf("failed"))
+	if !q.isQuarantined(secret) || q.isQuarantined(configMap) {
+		t.Errorf("expected only the secret to be quarantined")
+	}
+	if q.release("ConfigMap", "test/foo") != nil || q.release("Secret", "test/foo") == nil {
+		t.Errorf("expected the secret to be released by its kind")
+	}
+}
+
+func TestQuarantineForgetsDeletedObjects(t *testing.T) {
+	secret := &v1.Secret{ObjectMeta: meta.ObjectMeta{Namespace: "test", Name: "foo"}}
+	other := &v1.Secret{ObjectMeta: meta.ObjectMeta{Namespace: "test", Name: "bar"}}
+
+	syncCtx := &controllerContext{
+		queue:        newQueueHolder(workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())),
+		eventTypes:   newEventTypeTracker(),
+		quarantine:   newQuarantine(3),
+		syncTracker:  newSyncTracker(),
+		writes:       newWriteTracker(time.Minute),
+		expectations: newExpectations(time.Minute),
+	}
+	defer syncCtx.Queue().ShutDown()
+	for _, obj := range []*v1.Secret{secret, other} {
+		failures, _ := syncCtx.quarantine.failed(obj, fmt.Errorf("failed"))
+		syncCtx.syncTracker.syncFailed(obj, fmt.Errorf("failed"), failures)
+	}
+
+	syncCtx.enqueue(secret, SyncEventDelete, true)
+	if _, ok := syncCtx.quarantine.failures[objectKeyFor(secret)]; ok {
+		t.Errorf("expected the failures of the deleted secret to be forgotten")
+	}
+	if _, ok := syncCtx.quarantine.failures[objectKeyFor(other)]; !ok {
+		t.Errorf("expected the failures of the other secret to be kept")
+	}
+	if errors := syncCtx.syncTracker.debugInfo().Errors; len(errors) != 1 || errors[0].Key != "test/bar" {
+		t.Errorf("expected only the sync errors of the other secret, got %#v", errors)
+	}
+
+	// the quarantined object is forgotten too
+	syncCtx.quarantine.failed(other, fmt.Errorf("failed"))
+	syncCtx.quarantine.failed(other, fmt.Errorf("failed"))
+	if !syncCtx.quarantine.isQuarantined(other) {
+		t.Fatalf("expected the other secret to be quarantined")
+	}
+	syncCtx.enqueue(other, SyncEventDelete, true)
+	if syncCtx.quarantine.isQuarantined(other) || len(syncCtx.quarantine.failures) != 0 {
+		t.Errorf("expected the deleted secret to be removed from quarantine")
+	}
+}
@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/tools/cache"
+)
+
+// informerScope restricts the objects watched by the informers the factory builds. The list and watch calls are
+// filtered on the server side.
+type informerScope struct {
+	namespaces    []string
+	labelSelector string
+	fieldSelector string
+}
+
+// namespacesOrAll returns the namespaces to build the informers for.
+func (s informerScope) namespacesOrAll() []string {
+	if len(s.namespaces) == 0 {
+		return []string{metav1.NamespaceAll}
+	}
+	return s.namespaces
+}
+
+func (s informerScope) tweakListOptions(options *metav1.ListOptions) {
+	options.LabelSelector = s.labelSelector
+	options.FieldSelector = s.fieldSelector
+}
+
+// informerName returns the name of the informer for the namespace, "namespace/name" or just "name" for all namespaces.
+func (s informerScope) informerName(namespace, name string) string {
+	if len(namespace) == 0 {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// scopedInformers builds the informers of the factory scope that apply the transform, if set, and the starters that
+// run them.
+type scopedInformers func(scope informerScope, transform TransformFunc) ([]namedInformer, []InformerStarter)
+
+// ForNamespaces restricts the informers built by KubeInformer(), DynamicInformers() and MetadataInformers() to the given
+// namespaces.
+// One informer per namespace is built and all of them feed the controller queue, so the controller can run with
+// namespace-scoped RBAC. All namespaces are watched when not called.
+func (f *Factory) ForNamespaces(namespaces ...string) *Factory {
+	f.scope.namespaces = append(f.scope.namespaces, namespaces...)
+	return f
+}
+
+// WithLabelSelector restricts the informers built by KubeInformer(), DynamicInformers() and MetadataInformers() to the
+// objects matching the label selector. This panics when the selector is not valid.
+func (f *Factory) WithLabelSelector(selector string) *Factory {
+	if _, err := labels.Parse(selector); err != nil {
+		panic(fmt.Sprintf("invalid label selector %q: %v", selector, err))
+	}
+	f.scope.labelSelector = selector
+	return f
+}
+
+// WithFieldSelector restricts the informers built by KubeInformer(), DynamicInformers() and MetadataInformers() to the
+// objects matching the field selector, for example "metadata.name=config". This panics when the selector is not valid.
+func (f *Factory) WithFieldSelector(selector string) *Factory {
+	if _, err := fields.ParseSelector(selector); err != nil {
+		panic(fmt.Sprintf("invalid field selector %q: %v", selector, err))
+	}
+	f.scope.fieldSelector = selector
+	return f
+}
+
+// KubeInformer registers the typed informers of the given resources, scoped by ForNamespaces(), WithLabelSelector() and
+// WithFieldSelector(). The informers are built by the shared informer factory of every namespace, so the objects passed
+// to Sync() are the typed objects, for example *corev1.Secret. The informers are built when the controller is made, so
+// the order of the calls does not matter. The informers are named "namespace/resource.group" or just "resource.group"
+// for all namespaces, for example "tenant-a/secrets" or "deployments.apps", and they are started by the controller:
+//
+//	factory.ForNamespaces("tenant-a", "tenant-b").WithLabelSelector("app=web").
+//		KubeInformer(kubeClient, resync, corev1.SchemeGroupVersion.WithResource("secrets"))
+//
+// The shared informer factory cannot transform the objects, so WithTransform() cannot be used together with
+// KubeInformer(), use NewTransformingInformer() instead. This panics when the informer factory does not provide the
+// resource.
+func (f *Factory) KubeInformer(client kubernetes.Interface, resync time.Duration, resources ...schema.GroupVersionResource) *Factory {
+	for _, resource := range resources {
+		if _, err := informers.NewSharedInformerFactory(client, resync).ForResource(resource); err != nil {
+			panic(fmt.Sprintf("unable to build informer: %v", err))
+		}
+	}
+	f.scopedInformers = append(f.scopedInformers, func(scope informerScope, transform TransformFunc) ([]namedInformer, []InformerStarter) {
+		if transform != nil {
+			panic("WithTransform() cannot be used with KubeInformer(), the typed informers cannot be transformed")
+		}
+		var result []namedInformer
+		var starters []InformerStarter
+		for _, namespace := range scope.namespacesOrAll() {
+			kubeInformers := informers.NewSharedInformerFactoryWithOptions(client, resync, informers.WithNamespace(namespace), informers.WithTweakListOptions(scope.tweakListOptions))
+			for _, resource := range resources {
+				// the resource was checked when registered
+				informer, _ := kubeInformers.ForResource(resource)
+				name := resource.GroupResource().String()
+				result = append(result, namedInformer{name: scope.informerName(namespace, name), group: name, informer: informer.Informer()})
+			}
+			starters = append(starters, kubeInformers)
+		}
+		return result, starters
+	})
+	return f
+}
+
+// DynamicInformers registers the dynamic informers of the given resources scoped by ForNamespaces(),
+// WithLabelSelector() and WithFieldSelector(). The objects passed to Sync() are *unstructured.Unstructured.
+// The informer in every namespace is named "namespace/resource.group" and the informers are started by the controller.
+func (f *Factory) DynamicInformers(client dynamic.Interface, resync time.Duration, resources ...schema.GroupVersionResource) *Factory {
+	f.scopedInformers = append(f.scopedInformers, func(scope informerScope, transform TransformFunc) ([]namedInformer, []InformerStarter) {
+		var result []namedInformer
+		var starters []InformerStarter
+		for _, namespace := range scope.namespacesOrAll() {
+			for _, resource := range resources {
+				resourceClient := client.Resource(resource).Namespace(namespace)
+				lw := &cache.ListWatch{
+					ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+						scope.tweakListOptions(&options)
+						return resourceClient.List(options)
+					},
+					WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+						scope.tweakListOptions(&options)
+						return resourceClient.Watch(options)
+					},
+				}
+				informer := newScopedInformer(lw, &unstructured.Unstructured{}, resync, transform)
+				name := resource.GroupResource().String()
+				result = append(result, namedInformer{name: scope.informerName(namespace, name), group: name, informer: informer})
+				starters = append(starters, &informerRunner{informer: informer})
+			}
+		}
+		return result, starters
+	})
+	return f
+}
+
+// MetadataInformers registers the metadata informers of the given resources scoped by ForNamespaces(),
+// WithLabelSelector() and WithFieldSelector(). The metadata informers watch only the object metadata
+// (*metav1.PartialObjectMetadata), which cuts the memory and bandwidth for large resource types when the controller
+// needs just names, labels or owner references. GetObjectMeta() works the same as with the full objects.
+// The informer in every namespace is named "namespace/resource.group" and the informers are started by the controller.
+func (f *Factory) MetadataInformers(client metadata.Interface, resync time.Duration, resources ...schema.GroupVersionResource) *Factory {
+	f.scopedInformers = append(f.scopedInformers, func(scope informerScope, transform TransformFunc) ([]namedInformer, []InformerStarter) {
+		var result []namedInformer
+		var starters []InformerStarter
+		for _, namespace := range scope.namespacesOrAll() {
+			for _, resource := range resources {
+				resourceClient := client.Resource(resource).Namespace(namespace)
+				lw := &cache.ListWatch{
+					ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+						scope.tweakListOptions(&options)
+						return resourceClient.List(options)
+					},
+					WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+						scope.tweakListOptions(&options)
+						return resourceClient.Watch(options)
+					},
+				}
+				informer := newScopedInformer(lw, &metav1.PartialObjectMetadata{}, resync, transform)
+				name := resource.GroupResource().String()
+				result = append(result, namedInformer{name: scope.informerName(namespace, name), group: name, informer: informer})
+				starters = append(starters, &informerRunner{informer: informer})
+			}
+		}
+		return result, starters
+	})
+	return f
+}
+
+// newScopedInformer returns the informer indexed by namespace that applies the transform when it is set.
+func newScopedInformer(lw cache.ListerWatcher, objType runtime.Object, resync time.Duration, transform TransformFunc) cache.SharedIndexInformer {
+	if transform != nil {
+		return NewTransformingInformer(lw, objType, resync, transform)
+	}
+	return cache.NewSharedIndexInformer(lw, objType, resync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+}
+
+// buildInformers returns the informers registered with the factory together with the scoped informers.
+func (f *Factory) buildInformers() ([]namedInformer, []InformerStarter) {
+	namedInformers := append([]namedInformer{}, f.informers...)
+	starters := append([]InformerStarter{}, f.informerStarters...)
+	for _, build := range f.scopedInformers {
+		scoped, scopedStarters := build(f.scope, f.transform)
+		namedInformers = append(namedInformers, scoped...)
+		starters = append(starters, scopedStarters...)
+	}
+	return namedInformers, starters
+}
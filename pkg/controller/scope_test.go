@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func TestScopedKubeInformers(t *testing.T) {
+	secret := func(namespace, name, app string) *v1.Secret {
+		return &v1.Secret{ObjectMeta: meta.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{"app": app}}}
+	}
+	kubeClient := fake.NewSimpleClientset(
+		secret("tenant-a", "web", "web"),
+		secret("tenant-b", "web", "web"),
+		secret("tenant-b", "db", "db"),
+		secret("tenant-c", "web", "web"),
+	)
+	var lock sync.Mutex
+	listed := map[string]string{}
+	kubeClient.PrependReactor("list", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		restrictions := action.(clienttesting.ListAction).GetListRestrictions()
+		listed[action.GetNamespace()] = restrictions.Labels.String() + " " + restrictions.Fields.String()
+		return false, nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	synced := sets.NewString()
+	var indexed []string
+	controller := NewFactory().
+		KubeInformer(kubeClient, time.Minute, v1.SchemeGroupVersion.WithResource("secrets")).
+		WithInformersContext(ctx).
+		// the scope applies regardless of the order of calls
+		ForNamespaces("tenant-a", "tenant-b").
+		WithLabelSelector("app=web").
+		WithFieldSelector("type=Opaque").
+		// the indexers are added to the informers in all namespaces
+		WithIndexers("secrets", LabelIndexers("app")).
+		Sync(func(ctx context.Context, controllerContext Context) error {
+			objs, err := controllerContext.ByIndex("secrets", LabelIndexName("app"), "web")
+			if err != nil {
+				return err
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			synced.Insert(controllerContext.GetObjectMeta().GetNamespace() + "/" + controllerContext.GetObjectMeta().GetName())
+			indexed = nil
+			for _, obj := range objs {
+				indexed = append(indexed, queueKeyFor(obj))
+			}
+			return nil
+		}).Controller("ScopedController", events.NewInMemoryRecorder("scoped-controller"))
+	go controller.Run(ctx, 1)
+
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		return synced.Len() == 2 && len(indexed) == 2, nil
+	}); err != nil {
+		t.Fatalf("expected secrets from both namespaces to be synced, got %v", synced.List())
+	}
+
+	// give the controller time to sync objects outside of the scope
+	time.Sleep(200 * time.Millisecond)
+	lock.Lock()
+	defer lock.Unlock()
+	if expected := []string{"tenant-a/web", "tenant-b/web"}; !reflect.DeepEqual(synced.List(), expected) {
+		t.Errorf("expected only %v to be synced, got %v", expected, synced.List())
+	}
+	sort.Strings(indexed)
+	if expected := []string{"tenant-a/web", "tenant-b/web"}; !reflect.DeepEqual(indexed, expected) {
+		t.Errorf("expected the index lookup to return %v from all namespaces, got %v", expected, indexed)
+	}
+	if expected := map[string]string{"tenant-a": "app=web type=Opaque", "tenant-b": "app=web type=Opaque"}; !reflect.DeepEqual(listed, expected) {
+		t.Errorf("expected list calls %v, got %v", expected, listed)
+	}
+
+	var names []string
+	for _, informer := range controller.DebugInfo().Informers {
+		names = append(names, informer.Name)
+	}
+	sort.Strings(names)
+	if expected := []string{"tenant-a/secrets", "tenant-b/secrets"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected informers %v, got %v", expected, names)
+	}
+}
+
+func TestKubeInformerMisuse(t *testing.T) {
+	for name, misuse := range map[string]func(){
+		"unknown resource": func() {
+			NewFactory().KubeInformer(fake.NewSimpleClientset(), time.Minute, schema.GroupVersionResource{Version: "v1", Resource: "unknown"})
+		},
+		"transform": func() {
+			NewFactory().KubeInformer(fake.NewSimpleClientset(), time.Minute, v1.SchemeGroupVersion.WithResource("secrets")).
+				WithTransform(StripData).
+				WithInformersContext(context.TODO()).
+				Sync(func(ctx context.Context, controllerContext Context) error { return nil }).
+				Controller("TransformController", events.NewInMemoryRecorder("transform-controller"))
+		},
+		"informers context": func() {
+			NewFactory().KubeInformer(fake.NewSimpleClientset(), time.Minute, v1.SchemeGroupVersion.WithResource("secrets")).
+				Sync(func(ctx context.Context, controllerContext Context) error { return nil }).
+				Controller("ScopedController", events.NewInMemoryRecorder("scoped-controller"))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected misuse to panic")
+				}
+			}()
+			misuse()
+		})
+	}
+}
+
+func TestInvalidSelectors(t *testing.T) {
+	for name, scope := range map[string]func(f *Factory){
+		"label": func(f *Factory) { f.WithLabelSelector("a in (b") },
+		"field": func(f *Factory) { f.WithFieldSelector("a~b") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected invalid selector to panic")
+				}
+			}()
+			scope(NewFactory())
+		})
+	}
+}
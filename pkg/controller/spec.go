@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/yaml"
+)
+
+// ControllerSpec describes a simple controller that watches the given resources and runs the sync function when they
+// change. It allows to tune the controller without recompiling, for example:
+//
+//	name: secret-labeler
+//	resources:
+//	- version: v1
+//	  resource: secrets
+//	namespaces: [tenant-a, tenant-b]
+//	labelSelector: app=web
+//	resyncInterval: 10m
+//	workers: 2
+//	retry:
+//	  baseDelay: 1s
+//	  maxDelay: 5m
+//	  quarantineAfter: 10
+type ControllerSpec struct {
+	// Name is the controller name.
+	Name string `json:"name"`
+	// Resources lists the watched resources.
+	Resources []ResourceSpec `json:"resources"`
+	// Namespaces restricts the watched resources to the given namespaces. All namespaces are watched when empty.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// LabelSelector restricts the watched resources to the objects matching the selector.
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// ResyncInterval is how often the informers re-deliver all objects, so every object is synced periodically.
+	// Zero disables the resync.
+	ResyncInterval metav1.Duration `json:"resyncInterval,omitempty"`
+	// Workers is the number of workers. Defaults to 1.
+	Workers int `json:"workers,omitempty"`
+	// Retry configures how the failed syncs are retried.
+	Retry RetrySpec `json:"retry,omitempty"`
+}
+
+// ResourceSpec identifies the watched resource by its group, version and resource.
+type ResourceSpec struct {
+	Group    string `json:"group,omitempty"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+}
+
+// RetrySpec configures the retries of failed syncs.
+type RetrySpec struct {
+	// BaseDelay is the delay of the first retry, it doubles with every failure. Defaults to 5ms.
+	BaseDelay metav1.Duration `json:"baseDelay,omitempty"`
+	// MaxDelay is the maximum delay between retries. Defaults to 1000s.
+	MaxDelay metav1.Duration `json:"maxDelay,omitempty"`
+	// QuarantineAfter is the number of consecutive failures after which the object is quarantined, see
+	// Factory.QuarantineAfter(). The objects are retried forever when zero.
+	QuarantineAfter int `json:"quarantineAfter,omitempty"`
+}
+
+// GroupVersionResource returns the resource as schema.GroupVersionResource.
+func (r ResourceSpec) GroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: r.Group, Version: r.Version, Resource: r.Resource}
+}
+
+// LoadControllerSpec parses the controller spec from YAML (or JSON) and validates it. Unknown fields are rejected.
+func LoadControllerSpec(data []byte) (*ControllerSpec, error) {
+	spec := &ControllerSpec{}
+	if err := yaml.UnmarshalStrict(data, spec); err != nil {
+		return nil, fmt.Errorf("unable to parse controller spec: %w", err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// Validate returns an error if the spec is incomplete or invalid.
+func (s ControllerSpec) Validate() error {
+	if len(s.Name) == 0 {
+		return fmt.Errorf("controller spec: name is required")
+	}
+	if len(s.Resources) == 0 {
+		return fmt.Errorf("controller spec %q: at least one resource is required", s.Name)
+	}
+	for i, r := range s.Resources {
+		if len(r.Version) == 0 || len(r.Resource) == 0 {
+			return fmt.Errorf("controller spec %q: resources[%d]: version and resource are required", s.Name, i)
+		}
+	}
+	if _, err := labels.Parse(s.LabelSelector); err != nil {
+		return fmt.Errorf("controller spec %q: invalid label selector: %w", s.Name, err)
+	}
+	if s.ResyncInterval.Duration < 0 || s.Workers < 0 || s.Retry.BaseDelay.Duration < 0 || s.Retry.MaxDelay.Duration < 0 || s.Retry.QuarantineAfter < 0 {
+		return fmt.Errorf("controller spec %q: negative values are not allowed", s.Name)
+	}
+	if s.Retry.MaxDelay.Duration > 0 && s.Retry.BaseDelay.Duration > s.Retry.MaxDelay.Duration {
+		return fmt.Errorf("controller spec %q: retry base delay is greater than max delay", s.Name)
+	}
+	return nil
+}
+
+// WorkerCount returns the number of workers the controller should be run with.
+func (s ControllerSpec) WorkerCount() int {
+	if s.Workers == 0 {
+		return 1
+	}
+	return s.Workers
+}
+
+// Factory builds the controller factory that watches the spec resources via dynamic informers and calls the given
+// sync function. The objects passed to Sync() are *unstructured.Unstructured.
+// The informers are started by the controller and stopped when the given ctx is done, so the ctx must be cancelled when
+// the controller is removed. The returned factory can be customized further, for example via WithTransform() to reduce
+// the informer cache. Run the controller with WorkerCount() workers, for example:
+//
+//	factory, err := spec.Factory(ctx, dynamicClient, syncFn)
+//	...
+//	factory.WithTransform(controller.StripManagedFields)
+//	go factory.Controller(spec.Name, recorder).Run(ctx, spec.WorkerCount())
+func (s ControllerSpec) Factory(ctx context.Context, client dynamic.Interface, syncFn SyncFunc) (*Factory, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	resources := make([]schema.GroupVersionResource, 0, len(s.Resources))
+	for _, r := range s.Resources {
+		resources = append(resources, r.GroupVersionResource())
+	}
+	factory := NewFactory().Sync(syncFn).QuarantineAfter(s.Retry.QuarantineAfter).
+		ForNamespaces(s.Namespaces...).
+		WithLabelSelector(s.LabelSelector).
+		DynamicInformers(client, s.ResyncInterval.Duration, resources...).
+		WithInformersContext(ctx)
+
+	if s.Retry.BaseDelay.Duration > 0 || s.Retry.MaxDelay.Duration > 0 {
+		baseDelay, maxDelay := s.Retry.BaseDelay.Duration, s.Retry.MaxDelay.Duration
+		if baseDelay == 0 {
+			baseDelay = 5 * time.Millisecond
+		}
+		if maxDelay == 0 {
+			maxDelay = 1000 * time.Second
+		}
+		factory.WithRateLimiter(workqueue.NewItemExponentialFailureRateLimiter(baseDelay, maxDelay))
+	}
+	return factory, nil
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func makeUnstructuredConfigMap(namespace, name string, labels map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetLabels(labels)
+	return obj
+}
+
+func TestLoadControllerSpec(t *testing.T) {
+	spec, err := LoadControllerSpec([]byte(`
+name: configmap-controller
+resources:
+- version: v1
+  resource: configmaps
+namespaces: [tenant-a, tenant-b]
+labelSelector: app=web
+resyncInterval: 10m
+workers: 2
+retry:
+  baseDelay: 1s
+  maxDelay: 5m
+  quarantineAfter: 10
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if spec.Name != "configmap-controller" || spec.Resources[0].Resource != "configmaps" || spec.ResyncInterval.Duration != 10*time.Minute ||
+		spec.WorkerCount() != 2 || spec.Retry.MaxDelay.Duration != 5*time.Minute || spec.Retry.QuarantineAfter != 10 {
+		t.Errorf("unexpected spec: %#v", spec)
+	}
+
+	for _, tc := range []struct {
+		name, yaml, expectedErr string
+	}{
+		{name: "missing name", yaml: "resources: [{version: v1, resource: configmaps}]", expectedErr: "name is required"},
+		{name: "missing resources", yaml: "name: foo", expectedErr: "at least one resource is required"},
+		{name: "missing version", yaml: "name: foo\nresources: [{resource: configmaps}]", expectedErr: "version and resource are required"},
+		{name: "invalid selector", yaml: "name: foo\nresources: [{version: v1, resource: configmaps}]\nlabelSelector: 'a in (b'", expectedErr: "invalid label selector"},
+		{name: "unknown field", yaml: "name: foo\nresources: [{version: v1, resource: configmaps}]\nworker: 2", expectedErr: "unknown field"},
+		{name: "invalid retry", yaml: "name: foo\nresources: [{version: v1, resource: configmaps}]\nretry: {baseDelay: 1m, maxDelay: 1s}", expectedErr: "base delay is greater than max delay"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := LoadControllerSpec([]byte(tc.yaml)); err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+				t.Errorf("expected error containing %q, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestControllerSpecFactory(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		makeUnstructuredConfigMap("tenant-a", "web", map[string]string{"app": "web"}),
+		makeUnstructuredConfigMap("tenant-a", "db", map[string]string{"app": "db"}),
+		makeUnstructuredConfigMap("tenant-b", "web", map[string]string{"app": "web"}),
+		makeUnstructuredConfigMap("tenant-c", "web", map[string]string{"app": "web"}),
+	)
+	spec := ControllerSpec{
+		Name:          "ConfigMapController",
+		Resources:     []ResourceSpec{{Version: "v1", Resource: "configmaps"}},
+		Namespaces:    []string{"tenant-a", "tenant-b"},
+		LabelSelector: "app=web",
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	var lock sync.Mutex
+	synced := []string{}
+	factory, err := spec.Factory(ctx, client, func(ctx context.Context, controllerContext Context) error {
+		if _, ok := controllerContext.GetQueueObject().(*unstructured.Unstructured); !ok {
+			t.Errorf("expected unstructured object, got %T", controllerContext.GetQueueObject())
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		synced = append(synced, queueKeyFor(controllerContext.GetQueueObject()))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	controller := factory.Controller(spec.Name, events.NewInMemoryRecorder("spec-controller"))
+	go controller.Run(ctx, spec.WorkerCount())
+
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(synced) >= 2, nil
+	}); err != nil {
+		t.Fatalf("expected objects to be synced, got %v", synced)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	lock.Lock()
+	defer lock.Unlock()
+	sort.Strings(synced)
+	if expected := []string{"tenant-a/web", "tenant-b/web"}; !reflect.DeepEqual(synced, expected) {
+		t.Errorf("expected %v to be synced, got %v", expected, synced)
+	}
+	informers := []string{}
+	for _, i := range controller.DebugInfo().Informers {
+		informers = append(informers, i.Name)
+	}
+	if expected := []string{"tenant-a/configmaps", "tenant-b/configmaps"}; !reflect.DeepEqual(informers, expected) {
+		t.Errorf("expected informers %v, got %v", expected, informers)
+	}
+}
@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewControllerFunc builds the controller instance for the given ID. The kubeInformers are restricted to the namespace
+// of the instance and are started and stopped by the supervisor, so they must not be passed to WithInformerFactories().
+type NewControllerFunc func(id, namespace string, kubeInformers informers.SharedInformerFactory) (Controller, error)
+
+// DefaultSupervisorCacheSyncTimeout is how long Supervisor.Add() waits for the informer caches of the instance to sync.
+const DefaultSupervisorCacheSyncTimeout = time.Minute
+
+// Supervisor spawns, tracks and tears down controller instances keyed by an ID, for example a controller per tenant
+// namespace that is created at runtime and removed when the namespace goes away.
+// Every instance has its own namespace-scoped informers that are stopped together with the instance.
+type Supervisor struct {
+	kubeClient       kubernetes.Interface
+	resync           time.Duration
+	workers          int
+	newController    NewControllerFunc
+	cacheSyncTimeout time.Duration
+	transforms       []supervisedTransform
+
+	instances map[string]*supervisedController
+	lock      sync.Mutex
+}
+
+// supervisedTransform is the transform applied to the informers of the type in every instance.
+type supervisedTransform struct {
+	objType   runtime.Object
+	listWatch func(client kubernetes.Interface, namespace string) cache.ListerWatcher
+	transform TransformFunc
+}
+
+type supervisedController struct {
+	// controller is nil while the instance is starting.
+	controller Controller
+	// cancel stops both the controller and its informers.
+	cancel context.CancelFunc
+	// done is closed when the controller is shut down and removed from the supervisor.
+	done chan struct{}
+}
+
+// NewSupervisor returns supervisor that builds controller instances via newController and runs them with the given
+// number of workers. The informers of every instance use the given resync period.
+func NewSupervisor(kubeClient kubernetes.Interface, resync time.Duration, workers int, newController NewControllerFunc) *Supervisor {
+	return &Supervisor{
+		kubeClient:       kubeClient,
+		resync:           resync,
+		workers:          workers,
+		newController:    newController,
+		cacheSyncTimeout: DefaultSupervisorCacheSyncTimeout,
+		instances:        map[string]*supervisedController{},
+	}
+}
+
+// WithCacheSyncTimeout sets how long Add() waits for the informer caches of the instance to sync. When the caches are
+// not synced in time, the instance is stopped and Add() returns CacheSyncError.
+// If this is not called, DefaultSupervisorCacheSyncTimeout is used.
+func (s *Supervisor) WithCacheSyncTimeout(timeout time.Duration) *Supervisor {
+	s.cacheSyncTimeout = timeout
+	return s
+}
+
+// WithTransform applies the transform function to the objects of the given type, for example &corev1.Secret{}, before
+// they are stored in the informer cache of every instance, see NewTransformingInformer(). The objects are listed and
+// watched via the ListWatch returned for the namespace of the instance. The informer of the type is registered in the
+// kubeInformers passed to NewControllerFunc via InformerFor(), so the typed listers and informers return the
+// transformed objects:
+//
+//	supervisor.WithTransform(controller.StripData, &corev1.Secret{}, func(client kubernetes.Interface, namespace string) cache.ListerWatcher {
+//		return cache.NewListWatchFromClient(client.CoreV1().RESTClient(), "secrets", namespace, fields.Everything())
+//	})
+func (s *Supervisor) WithTransform(transform TransformFunc, objType runtime.Object, listWatch func(client kubernetes.Interface, namespace string) cache.ListerWatcher) *Supervisor {
+	s.transforms = append(s.transforms, supervisedTransform{objType: objType, listWatch: listWatch, transform: transform})
+	return s
+}
+
+// Add builds the controller instance for the given ID watching the given namespace, starts its informers and starts
+// the controller. It blocks until the informer caches are synced or the cache sync timeout expires, other instances
+// can be added, listed and removed meanwhile. The instance is stopped when the ctx is cancelled or when it is removed.
+// When the controller stops on its own, for example after panic or leader election loss, the instance is removed.
+func (s *Supervisor) Add(ctx context.Context, id, namespace string) error {
+	// reserve the ID, the instance is started without holding the lock
+	s.lock.Lock()
+	if _, exists := s.instances[id]; exists {
+		s.lock.Unlock()
+		return fmt.Errorf("controller %q already exists", id)
+	}
+	instanceCtx, cancel := context.WithCancel(ctx)
+	instance := &supervisedController{cancel: cancel, done: make(chan struct{})}
+	s.instances[id] = instance
+	s.lock.Unlock()
+
+	controller, err := s.start(instanceCtx, cancel, id, namespace)
+	if err != nil {
+		cancel()
+		s.forget(id, instance)
+		return err
+	}
+	s.lock.Lock()
+	instance.controller = controller
+	s.lock.Unlock()
+
+	go func() {
+		controller.Wait()
+		// stop the informers when the controller stopped on its own
+		cancel()
+		s.forget(id, instance)
+	}()
+	return nil
+}
+
+// start builds the controller, starts its informers and starts the controller. The cancel stops the instance when
+// the caches do not sync before the cache sync timeout.
+func (s *Supervisor) start(ctx context.Context, cancel context.CancelFunc, id, namespace string) (Controller, error) {
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(s.kubeClient, s.resync, informers.WithNamespace(namespace))
+	for _, t := range s.transforms {
+		t := t
+		kubeInformers.InformerFor(t.objType, func(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+			return NewTransformingInformer(t.listWatch(client, namespace), t.objType, resync, t.transform)
+		})
+	}
+	controller, err := s.newController(id, namespace, kubeInformers)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build controller %q: %w", id, err)
+	}
+
+	// informers must be requested by the controller before the factory is started
+	kubeInformers.Start(ctx.Done())
+	timeout := time.AfterFunc(s.cacheSyncTimeout, cancel)
+	err = controller.Start(ctx, s.workers)
+	if !timeout.Stop() {
+		err = &CacheSyncError{Informers: unsyncedInformers(controller), Timeout: s.cacheSyncTimeout}
+	}
+	if err != nil {
+		cancel()
+		controller.Wait()
+		return nil, fmt.Errorf("unable to start controller %q: %w", id, err)
+	}
+	return controller, nil
+}
+
+// forget removes the instance unless the ID was already removed or reused, and marks the instance as done.
+func (s *Supervisor) forget(id string, instance *supervisedController) {
+	s.lock.Lock()
+	if s.instances[id] == instance {
+		delete(s.instances, id)
+	}
+	s.lock.Unlock()
+	close(instance.done)
+}
+
+// unsyncedInformers returns the names of the controller informers that are not synced.
+func unsyncedInformers(controller Controller) []string {
+	var result []string
+	for _, informer := range controller.DebugInfo().Informers {
+		if !informer.Synced {
+			result = append(result, informer.Name)
+		}
+	}
+	return result
+}
+
+// Remove stops the controller instance with the given ID together with its informers and waits for the controller
+// shutdown. Returns false if there is no such instance.
+func (s *Supervisor) Remove(id string) bool {
+	s.lock.Lock()
+	instance, exists := s.instances[id]
+	delete(s.instances, id)
+	s.lock.Unlock()
+	if !exists {
+		return false
+	}
+	instance.cancel()
+	<-instance.done
+	return true
+}
+
+// Controller returns the controller instance with the given ID.
+func (s *Supervisor) Controller(id string) (Controller, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	instance, exists := s.instances[id]
+	if !exists || instance.controller == nil {
+		return nil, false
+	}
+	return instance.controller, true
+}
+
+// IDs returns sorted IDs of all controller instances, including the instances being started.
+func (s *Supervisor) IDs() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ids := make([]string, 0, len(s.instances))
+	for id := range s.instances {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Stop removes all controller instances and waits for their shutdown.
+func (s *Supervisor) Stop() {
+	for _, id := range s.IDs() {
+		s.Remove(id)
+	}
+}
@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func TestSupervisor(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(
+		&v1.Secret{ObjectMeta: meta.ObjectMeta{Name: "secret-a", Namespace: "tenant-a"}},
+		&v1.Secret{ObjectMeta: meta.ObjectMeta{Name: "secret-b", Namespace: "tenant-b"}},
+	)
+
+	var lock sync.Mutex
+	synced := map[string][]string{}
+	supervisor := NewSupervisor(kubeClient, 1*time.Minute, 1, func(id, namespace string, kubeInformers informers.SharedInformerFactory) (Controller, error) {
+		return NewFactory().Informers(kubeInformers.Core().V1().Secrets().Informer()).Sync(func(ctx context.Context, controllerContext Context) error {
+			lock.Lock()
+			defer lock.Unlock()
+			synced[id] = append(synced[id], queueKeyFor(controllerContext.GetQueueObject()))
+			return nil
+		}).Controller("TenantController-"+id, events.NewInMemoryRecorder(id)), nil
+	})
+	defer supervisor.Stop()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	for _, tenant := range []string{"a", "b"} {
+		if err := supervisor.Add(ctx, tenant, "tenant-"+tenant); err != nil {
+			t.Fatalf("unexpected error adding %q: %v", tenant, err)
+		}
+	}
+	if err := supervisor.Add(ctx, "a", "tenant-a"); err == nil {
+		t.Errorf("expected error adding duplicate controller")
+	}
+
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(synced["a"]) > 0 && len(synced["b"]) > 0, nil
+	}); err != nil {
+		t.Fatalf("expected both controllers to sync, got %#v", synced)
+	}
+	lock.Lock()
+	if !reflect.DeepEqual(synced, map[string][]string{"a": {"tenant-a/secret-a"}, "b": {"tenant-b/secret-b"}}) {
+		t.Errorf("expected controllers to see only objects in their namespace, got %#v", synced)
+	}
+	lock.Unlock()
+
+	controllerA, ok := supervisor.Controller("a")
+	if !ok {
+		t.Fatalf("expected controller a to exist")
+	}
+	if !supervisor.Remove("a") {
+		t.Fatalf("expected controller a to be removed")
+	}
+	select {
+	case <-controllerA.ShutdownContext().Done():
+	default:
+		t.Errorf("expected removed controller to be shut down")
+	}
+	if supervisor.Remove("a") {
+		t.Errorf("expected second remove to be no-op")
+	}
+	if ids := supervisor.IDs(); !reflect.DeepEqual(ids, []string{"b"}) {
+		t.Errorf("expected only controller b to be left, got %v", ids)
+	}
+
+	// the removed controller does not receive any new events
+	if _, err := kubeClient.CoreV1().Secrets("tenant-a").Create(&v1.Secret{ObjectMeta: meta.ObjectMeta{Name: "secret-a2", Namespace: "tenant-a"}}); err != nil {
+		t.Fatalf("failed to create fake secret: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	lock.Lock()
+	if len(synced["a"]) != 1 {
+		t.Errorf("expected no syncs after removal, got %v", synced["a"])
+	}
+	lock.Unlock()
+
+	// the ID can be reused after removal
+	if err := supervisor.Add(ctx, "a", "tenant-a"); err != nil {
+		t.Fatalf("unexpected error re-adding controller: %v", err)
+	}
+}
+
+func TestSupervisorBuildError(t *testing.T) {
+	supervisor := NewSupervisor(fake.NewSimpleClientset(), 1*time.Minute, 1, func(id, namespace string, kubeInformers informers.SharedInformerFactory) (Controller, error) {
+		return nil, fmt.Errorf("invalid tenant")
+	})
+	if err := supervisor.Add(context.TODO(), "a", "tenant-a"); err == nil {
+		t.Fatalf("expected build error")
+	}
+	if ids := supervisor.IDs(); len(ids) != 0 {
+		t.Errorf("expected no controllers, got %v", ids)
+	}
+}
+
+func TestSupervisorCacheSyncTimeout(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(&v1.Secret{ObjectMeta: meta.ObjectMeta{Name: "secret-b", Namespace: "tenant-b"}})
+	kubeClient.PrependReactor("list", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() == "tenant-a" {
+			return true, nil, fmt.Errorf("secrets is forbidden")
+		}
+		return false, nil, nil
+	})
+	supervisor := NewSupervisor(kubeClient, 1*time.Minute, 1, func(id, namespace string, kubeInformers informers.SharedInformerFactory) (Controller, error) {
+		return NewFactory().NamedInformer("secrets", kubeInformers.Core().V1().Secrets().Informer()).Sync(func(ctx context.Context, controllerContext Context) error {
+			return nil
+		}).Controller("TenantController-"+id, events.NewInMemoryRecorder(id)), nil
+	}).WithCacheSyncTimeout(time.Second)
+	defer supervisor.Stop()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		result <- supervisor.Add(ctx, "a", "tenant-a")
+	}()
+
+	// the instance that never syncs does not block the others
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		return len(supervisor.IDs()) == 1, nil
+	}); err != nil {
+		t.Fatalf("expected the ID to be reserved, got %v", supervisor.IDs())
+	}
+	if err := supervisor.Add(ctx, "a", "tenant-a"); err == nil {
+		t.Errorf("expected error adding the controller that is being started")
+	}
+	if err := supervisor.Add(ctx, "b", "tenant-b"); err != nil {
+		t.Fatalf("unexpected error adding %q: %v", "b", err)
+	}
+
+	var syncErr *CacheSyncError
+	select {
+	case err := <-result:
+		if !errors.As(err, &syncErr) || !reflect.DeepEqual(syncErr.Informers, []string{"secrets"}) {
+			t.Errorf("expected CacheSyncError for the secrets informer, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected Add() to return after the cache sync timeout")
+	}
+	if ids := supervisor.IDs(); !reflect.DeepEqual(ids, []string{"b"}) {
+		t.Errorf("expected only controller b to be left, got %v", ids)
+	}
+}
+
+func TestSupervisorForgetsStoppedController(t *testing.T) {
+	supervisor := NewSupervisor(fake.NewSimpleClientset(), 1*time.Minute, 1, func(id, namespace string, kubeInformers informers.SharedInformerFactory) (Controller, error) {
+		return NewFactory().Informers(kubeInformers.Core().V1().Secrets().Informer()).Sync(func(ctx context.Context, controllerContext Context) error {
+			return nil
+		}).Controller("TenantController-"+id, events.NewInMemoryRecorder(id)), nil
+	})
+	defer supervisor.Stop()
+
+	if err := supervisor.Add(context.TODO(), "a", "tenant-a"); err != nil {
+		t.Fatal(err)
+	}
+	controller, _ := supervisor.Controller("a")
+	controller.Stop(ErrLeaderLost)
+
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		return len(supervisor.IDs()) == 0, nil
+	}); err != nil {
+		t.Fatalf("expected the stopped controller to be removed, got %v", supervisor.IDs())
+	}
+	if err := supervisor.Add(context.TODO(), "a", "tenant-a"); err != nil {
+		t.Errorf("unexpected error re-adding the stopped controller: %v", err)
+	}
+}
+
+func TestSupervisorTransform(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "secret-a", Namespace: "tenant-a"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	synced := make(chan *v1.Secret, 1)
+	supervisor := NewSupervisor(kubeClient, 1*time.Minute, 1, func(id, namespace string, kubeInformers informers.SharedInformerFactory) (Controller, error) {
+		return NewFactory().Informers(kubeInformers.Core().V1().Secrets().Informer()).Sync(func(ctx context.Context, controllerContext Context) error {
+			select {
+			case synced <- controllerContext.GetQueueObject().(*v1.Secret):
+			default:
+			}
+			return nil
+		}).Controller("TenantController-"+id, events.NewInMemoryRecorder(id)), nil
+	}).WithTransform(StripData, &v1.Secret{}, func(client kubernetes.Interface, namespace string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			ListFunc: func(options meta.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().Secrets(namespace).List(options)
+			},
+			WatchFunc: func(options meta.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().Secrets(namespace).Watch(options)
+			},
+		}
+	})
+	defer supervisor.Stop()
+
+	if err := supervisor.Add(context.TODO(), "a", "tenant-a"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case secret := <-synced:
+		if secret.Name != "secret-a" || len(secret.Data) > 0 {
+			t.Errorf("expected the secret with data stripped, got %#v", secret)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("test timeout")
+	}
+}
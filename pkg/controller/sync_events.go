@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// Reasons of the events recorded for sync failures.
+const (
+	SyncFailedReason    = "SyncFailed"
+	SyncRecoveredReason = "SyncRecovered"
+)
+
+// defaultSyncErrorEventsInterval is used when no interval is given.
+const defaultSyncErrorEventsInterval = 5 * time.Minute
+
+// syncEventsBurst is the number of events with different message that can be recorded for single key and reason
+// before the rate limiting kicks in.
+const syncEventsBurst = 3
+
+// syncErrorEvents records the sync failures as warning events on the involved object.
+// The events are deduplicated and rate-limited per object and reason. The same message for the same key is recorded
+// at most once per interval and different messages for the same key are limited by token bucket refilled once per interval.
+// When an object that had failure event recorded is synced successfully, the recovery event is recorded.
+type syncErrorEvents struct {
+	// recorder records the events on the involved object. When not set, the controller recorder is used.
+	recorder         record.EventRecorder
+	fallbackRecorder events.Recorder
+	interval         time.Duration
+	now              func() time.Time
+
+	failing map[string]*failingKey
+	lock    sync.Mutex
+}
+
+type failingKey struct {
+	limiter      *rate.Limiter
+	lastMessage  string
+	lastRecorded time.Time
+	failures     int
+}
+
+func newSyncErrorEvents(recorder record.EventRecorder, fallbackRecorder events.Recorder, interval time.Duration) *syncErrorEvents {
+	if interval <= 0 {
+		interval = defaultSyncErrorEventsInterval
+	}
+	return &syncErrorEvents{
+		recorder:         recorder,
+		fallbackRecorder: fallbackRecorder,
+		interval:         interval,
+		now:              time.Now,
+		failing:          map[string]*failingKey{},
+	}
+}
+
+// failed records the warning event for the sync failure unless it is deduplicated or rate-limited.
+func (e *syncErrorEvents) failed(obj runtime.Object, err error) {
+	if e == nil || obj == nil {
+		return
+	}
+	key := objectKeyFor(obj)
+	message := fmt.Sprintf("Failed to sync %s %q: %v", objectKind(obj), queueKeyFor(obj), err)
+
+	e.lock.Lock()
+	state, ok := e.failing[key]
+	if !ok {
+		state = &failingKey{limiter: rate.NewLimiter(rate.Every(e.interval), syncEventsBurst)}
+		e.failing[key] = state
+	}
+	state.failures++
+	now := e.now()
+	emit := !(state.lastMessage == message && now.Sub(state.lastRecorded) < e.interval) && state.limiter.AllowN(now, 1)
+	if emit {
+		state.lastMessage = message
+		state.lastRecorded = now
+	}
+	e.lock.Unlock()
+
+	if emit {
+		e.record(obj, corev1.EventTypeWarning, SyncFailedReason, message)
+	}
+}
+
+// succeeded records the recovery event when the object previously failed and had the failure event recorded.
+func (e *syncErrorEvents) succeeded(obj runtime.Object) {
+	if e == nil || obj == nil {
+		return
+	}
+	key := objectKeyFor(obj)
+
+	e.lock.Lock()
+	state, ok := e.failing[key]
+	delete(e.failing, key)
+	e.lock.Unlock()
+
+	if !ok || state.lastRecorded.IsZero() {
+		return
+	}
+	e.record(obj, corev1.EventTypeNormal, SyncRecoveredReason, fmt.Sprintf("Successfully synced %s %q after %d failures", objectKind(obj), queueKeyFor(obj), state.failures))
+}
+
+func (e *syncErrorEvents) record(obj runtime.Object, eventType, reason, message string) {
+	if e.recorder != nil {
+		obj, _ = unwrapClusterObject(obj)
+		e.recorder.Event(obj, eventType, reason, message)
+		return
+	}
+	if eventType == corev1.EventTypeWarning {
+		e.fallbackRecorder.Warning(reason, message)
+	} else {
+		e.fallbackRecorder.Event(reason, message)
+	}
+}
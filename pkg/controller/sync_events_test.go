@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/record"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func drainEvents(recorder *record.FakeRecorder) []string {
+	result := []string{}
+	for {
+		select {
+		case e := <-recorder.Events:
+			result = append(result, e)
+		default:
+			return result
+		}
+	}
+}
+
+func TestSyncErrorEvents(t *testing.T) {
+	recorder := record.NewFakeRecorder(100)
+	now := time.Now()
+	e := newSyncErrorEvents(recorder, events.NewInMemoryRecorder("test"), time.Minute)
+	e.now = func() time.Time { return now }
+
+	secret := makeFakeSecret()
+
+	// identical failures are deduplicated
+	for i := 0; i < 5; i++ {
+		e.failed(secret, fmt.Errorf("boom"))
+	}
+	if got := drainEvents(recorder); len(got) != 1 || got[0] != `Warning SyncFailed Failed to sync Secret "test/test-secret": boom` {
+		t.Fatalf("expected single deduplicated event, got %#v", got)
+	}
+
+	// different messages are rate-limited
+	for i := 0; i < 10; i++ {
+		e.failed(secret, fmt.Errorf("boom #%d", i))
+	}
+	if got := drainEvents(recorder); len(got) != syncEventsBurst-1 {
+		t.Fatalf("expected %d rate-limited events, got %#v", syncEventsBurst-1, got)
+	}
+
+	// the same message is recorded again after interval
+	now = now.Add(time.Minute)
+	e.failed(secret, fmt.Errorf("boom #9"))
+	if got := drainEvents(recorder); len(got) != 1 {
+		t.Fatalf("expected event after interval, got %#v", got)
+	}
+
+	e.succeeded(secret)
+	if got := drainEvents(recorder); len(got) != 1 || got[0] != `Normal SyncRecovered Successfully synced Secret "test/test-secret" after 16 failures` {
+		t.Fatalf("expected recovery event, got %#v", got)
+	}
+
+	// no recovery event for objects that did not fail
+	e.succeeded(secret)
+	if got := drainEvents(recorder); len(got) != 0 {
+		t.Fatalf("expected no events, got %#v", got)
+	}
+}
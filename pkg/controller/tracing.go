@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Attribute keys set on every Sync() span.
+const (
+	TraceKeyController = "controller.name"
+	TraceKeyQueueKey   = "controller.key"
+	TraceKeyEventType  = "controller.event_type"
+	TraceKeyRetryCount = "controller.retry_count"
+	TraceKeyOutcome    = "controller.outcome"
+)
+
+// Values for the TraceKeyOutcome attribute.
+const (
+	SyncOutcomeSuccess = "success"
+	SyncOutcomeError   = "error"
+)
+
+// Attribute is a key/value pair attached to a span.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// TracerProvider provides tracers. It is modelled after OpenTelemetry TracerProvider so it can be backed by
+// OpenTelemetry SDK or any other tracing library.
+type TracerProvider interface {
+	// Tracer returns a tracer with the given instrumentation name.
+	Tracer(name string) Tracer
+}
+
+// Tracer creates spans.
+type Tracer interface {
+	// Start creates a span and a context containing the newly created span.
+	// If the ctx passed already contains a span, the new span is its child.
+	Start(ctx context.Context, spanName string, attributes ...Attribute) (context.Context, Span)
+}
+
+// Span represents a single operation within a trace.
+type Span interface {
+	// SetAttributes sets the attributes of the span.
+	SetAttributes(attributes ...Attribute)
+
+	// RecordError records the error as the span failure.
+	RecordError(err error)
+
+	// End completes the span.
+	End()
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpan returns a copy of ctx with the span set.
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext returns the current span from ctx. If there is no span, a no-op span is returned.
+// The ctx passed to SyncFunc carry the Sync() span, so client calls inside the Sync() can create child spans.
+func SpanFromContext(ctx context.Context) Span {
+	if span, ok := ctx.Value(spanContextKey{}).(Span); ok {
+		return span
+	}
+	return noopSpan{}
+}
+
+type noopTracerProvider struct{}
+
+func (noopTracerProvider) Tracer(string) Tracer {
+	return noopTracer{}
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
+
+// SpanData is a snapshot of finished span recorded by in-memory tracer provider.
+type SpanData struct {
+	Name         string
+	Tracer       string
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Attributes   map[string]interface{}
+	Err          error
+	StartTime    time.Time
+	EndTime      time.Time
+}
+
+// InMemoryTracerProvider is a tracer provider that stores all finished spans in memory.
+// This provider should be only used in unit tests.
+type InMemoryTracerProvider struct {
+	spans []SpanData
+	lock  sync.Mutex
+}
+
+var _ TracerProvider = &InMemoryTracerProvider{}
+
+// NewInMemoryTracerProvider returns tracer provider that records all finished spans in memory and allow to replay them
+// using the Spans() method.
+func NewInMemoryTracerProvider() *InMemoryTracerProvider {
+	return &InMemoryTracerProvider{}
+}
+
+func (p *InMemoryTracerProvider) Tracer(name string) Tracer {
+	return &inMemoryTracer{name: name, provider: p}
+}
+
+// Spans returns list of finished spans.
+func (p *InMemoryTracerProvider) Spans() []SpanData {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]SpanData{}, p.spans...)
+}
+
+func (p *InMemoryTracerProvider) export(span SpanData) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.spans = append(p.spans, span)
+}
+
+type inMemoryTracer struct {
+	name     string
+	provider *InMemoryTracerProvider
+}
+
+func (t *inMemoryTracer) Start(ctx context.Context, spanName string, attributes ...Attribute) (context.Context, Span) {
+	span := &inMemorySpan{
+		provider: t.provider,
+		data: SpanData{
+			Name:       spanName,
+			Tracer:     t.name,
+			TraceID:    randomID(16),
+			SpanID:     randomID(8),
+			Attributes: map[string]interface{}{},
+			StartTime:  time.Now(),
+		},
+	}
+	if parent, ok := SpanFromContext(ctx).(*inMemorySpan); ok {
+		span.data.TraceID = parent.data.TraceID
+		span.data.ParentSpanID = parent.data.SpanID
+	}
+	span.SetAttributes(attributes...)
+	return ContextWithSpan(ctx, span), span
+}
+
+type inMemorySpan struct {
+	provider *InMemoryTracerProvider
+	data     SpanData
+	ended    bool
+	lock     sync.Mutex
+}
+
+func (s *inMemorySpan) SetAttributes(attributes ...Attribute) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, a := range attributes {
+		s.data.Attributes[a.Key] = a.Value
+	}
+}
+
+func (s *inMemorySpan) RecordError(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data.Err = err
+}
+
+func (s *inMemorySpan) End() {
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	data.Attributes = make(map[string]interface{}, len(s.data.Attributes))
+	for k, v := range s.data.Attributes {
+		data.Attributes[k] = v
+	}
+	s.lock.Unlock()
+	s.provider.export(data)
+}
+
+func randomID(size int) string {
+	b := make([]byte, size)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func TestSyncTracing(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(makeFakeSecret())
+
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	go kubeInformers.Start(ctx.Done())
+
+	provider := NewInMemoryTracerProvider()
+	clientTracer := provider.Tracer("client")
+	controllerSynced := make(chan struct{})
+	syncCount := 0
+	controller := NewFactory().Informers(kubeInformers.Core().V1().Secrets().Informer()).WithTracerProvider(provider).Sync(func(ctx context.Context, controllerContext Context) error {
+		_, span := clientTracer.Start(ctx, "GetSecret")
+		span.End()
+		syncCount++
+		if syncCount == 1 {
+			return fmt.Errorf("first sync fails")
+		}
+		close(controllerSynced)
+		return nil
+	}).Controller("TracedController", events.NewInMemoryRecorder("traced-controller"))
+
+	go controller.Run(ctx, 1)
+
+	select {
+	case <-controllerSynced:
+	case <-time.After(30 * time.Second):
+		t.Fatal("test timeout")
+	}
+
+	// the sync span is finished after the sync function returns
+	var syncSpans, clientSpans []SpanData
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		syncSpans, clientSpans = nil, nil
+		for _, s := range provider.Spans() {
+			switch s.Name {
+			case "Sync":
+				syncSpans = append(syncSpans, s)
+			case "GetSecret":
+				clientSpans = append(clientSpans, s)
+			}
+		}
+		return len(syncSpans) == 2 && len(clientSpans) == 2, nil
+	}); err != nil {
+		t.Fatalf("expected 2 sync and 2 client spans, got %#v", provider.Spans())
+	}
+
+	expected := []map[string]interface{}{
+		{TraceKeyController: "TracedController", TraceKeyQueueKey: "test/test-secret", TraceKeyEventType: SyncEventAdd, TraceKeyRetryCount: 0, TraceKeyOutcome: SyncOutcomeError},
+		{TraceKeyController: "TracedController", TraceKeyQueueKey: "test/test-secret", TraceKeyEventType: SyncEventRequeue, TraceKeyRetryCount: 1, TraceKeyOutcome: SyncOutcomeSuccess},
+	}
+	for i := range syncSpans {
+		for key, value := range expected[i] {
+			if syncSpans[i].Attributes[key] != value {
+				t.Errorf("span #%d: expected %q to be %v, got %v", i, key, value, syncSpans[i].Attributes[key])
+			}
+		}
+		if clientSpans[i].ParentSpanID != syncSpans[i].SpanID || clientSpans[i].TraceID != syncSpans[i].TraceID {
+			t.Errorf("span #%d: expected client span to be child of sync span", i)
+		}
+	}
+	if syncSpans[0].Err == nil {
+		t.Errorf("expected failed sync span to record the error")
+	}
+}
+
+func TestEventTypesKeyedByKind(t *testing.T) {
+	secret := &v1.Secret{ObjectMeta: meta.ObjectMeta{Namespace: "test", Name: "foo"}}
+	configMap := &v1.ConfigMap{ObjectMeta: meta.ObjectMeta{Namespace: "test", Name: "foo"}}
+
+	eventTypes := newEventTypeTracker()
+	eventTypes.observe(secret, SyncEventDelete)
+	eventTypes.observe(configMap, SyncEventAdd)
+	if eventType := eventTypes.pop(secret); eventType != SyncEventDelete {
+		t.Errorf("expected the secret event type to be %q, got %q", SyncEventDelete, eventType)
+	}
+}
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// TransformFunc transforms the object before it is stored in the informer cache. The objects are freshly decoded from
+// the API server response, so the function can modify and return the given object.
+type TransformFunc func(obj runtime.Object) runtime.Object
+
+// transformingListWatch applies the transform function to all objects returned by the list and watch calls.
+type transformingListWatch struct {
+	lw        cache.ListerWatcher
+	transform TransformFunc
+}
+
+// NewTransformingListWatch wraps the ListerWatcher, so the transform function is applied to every object before it
+// reaches the informer. Use it to reduce the memory used by the informer cache, for example by dropping data the
+// controller never reads, see StripData(), StripManagedFields() and StripAnnotations().
+// The cache then holds incomplete objects, so they must never be used as a base for update.
+func NewTransformingListWatch(lw cache.ListerWatcher, transform TransformFunc) cache.ListerWatcher {
+	return &transformingListWatch{lw: lw, transform: transform}
+}
+
+// NewTransformingInformer returns the shared informer that applies the transform function to the objects before they
+// are stored in the cache. Register it with the factory via Informers() or NamedInformer(). To make the typed listers
+// of the shared informer factory use it, create it via InformerFor() before the typed informer is requested.
+// The cache then holds incomplete objects, so they must never be used as a base for update:
+//
+//	secretInformer := kubeInformers.InformerFor(&corev1.Secret{}, func(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+//		return controller.NewTransformingInformer(lw, &corev1.Secret{}, resync, controller.StripData)
+//	})
+func NewTransformingInformer(lw cache.ListerWatcher, objType runtime.Object, resync time.Duration, transform TransformFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(NewTransformingListWatch(lw, transform), objType, resync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+}
+
+// WithTransform applies the transform function to the objects of the informers built by DynamicInformers() and
+// MetadataInformers() before they are stored in the cache, see NewTransformingInformer(). The transforms given in
+// multiple calls are applied in order. The typed informers built by KubeInformer() cannot be transformed, so
+// Controller() panics when both are used.
+func (f *Factory) WithTransform(transform TransformFunc) *Factory {
+	if f.transform == nil {
+		f.transform = transform
+	} else {
+		f.transform = ChainTransforms(f.transform, transform)
+	}
+	return f
+}
+
+func (t *transformingListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
+	list, err := t.lw.List(options)
+	if err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i] = t.transform(items[i])
+	}
+	if err := meta.SetList(list, items); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (t *transformingListWatch) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	w, err := t.lw.Watch(options)
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+		// the error events carry the status and the bookmarks carry only the resourceVersion
+		if event.Type != watch.Error && event.Type != watch.Bookmark {
+			event.Object = t.transform(event.Object)
+		}
+		return event, true
+	}), nil
+}
+
+// ChainTransforms returns the transform function that applies all given transform functions in order.
+func ChainTransforms(transforms ...TransformFunc) TransformFunc {
+	return func(obj runtime.Object) runtime.Object {
+		for _, transform := range transforms {
+			obj = transform(obj)
+		}
+		return obj
+	}
+}
+
+// StripData drops the data of secrets and config maps. Use it when the controller reacts only to the metadata.
+func StripData(obj runtime.Object) runtime.Object {
+	switch t := obj.(type) {
+	case *corev1.Secret:
+		t.Data = nil
+		t.StringData = nil
+	case *corev1.ConfigMap:
+		t.Data = nil
+		t.BinaryData = nil
+	case *unstructured.Unstructured:
+		unstructured.RemoveNestedField(t.Object, "data")
+		unstructured.RemoveNestedField(t.Object, "stringData")
+		unstructured.RemoveNestedField(t.Object, "binaryData")
+	}
+	return obj
+}
+
+// StripManagedFields drops the managed fields of server-side apply, which are often larger than the object itself.
+func StripManagedFields(obj runtime.Object) runtime.Object {
+	if metaObj, err := meta.Accessor(obj); err == nil {
+		metaObj.SetManagedFields(nil)
+	}
+	return obj
+}
+
+// StripAnnotations returns the transform function that drops the annotations with the given keys, for example
+// corev1.LastAppliedConfigAnnotation which holds the copy of the whole object.
+func StripAnnotations(keys ...string) TransformFunc {
+	return func(obj runtime.Object) runtime.Object {
+		return stripAnnotations(obj, func(key, value string) bool {
+			for _, k := range keys {
+				if k == key {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// StripLargeAnnotations returns the transform function that drops the annotations with value longer than maxBytes.
+func StripLargeAnnotations(maxBytes int) TransformFunc {
+	return func(obj runtime.Object) runtime.Object {
+		return stripAnnotations(obj, func(key, value string) bool {
+			return len(value) > maxBytes
+		})
+	}
+}
+
+func stripAnnotations(obj runtime.Object, strip func(key, value string) bool) runtime.Object {
+	metaObj, err := meta.Accessor(obj)
+	if err != nil {
+		return obj
+	}
+	annotations := metaObj.GetAnnotations()
+	if len(annotations) == 0 {
+		return obj
+	}
+	kept := make(map[string]string, len(annotations))
+	for key, value := range annotations {
+		if !strip(key, value) {
+			kept[key] = value
+		}
+	}
+	if len(kept) == 0 {
+		kept = nil
+	}
+	metaObj.SetAnnotations(kept)
+	return obj
+}
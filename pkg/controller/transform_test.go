@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func TestTransformingInformer(t *testing.T) {
+	secret := makeFakeSecret()
+	secret.Annotations = map[string]string{
+		v1.LastAppliedConfigAnnotation: `{"data":{"test":""}}`,
+		"small":                        "kept",
+	}
+	secret.ManagedFields = []meta.ManagedFieldsEntry{{Manager: "kubectl"}}
+	kubeClient := fake.NewSimpleClientset(secret)
+
+	lw := &cache.ListWatch{
+		ListFunc: func(options meta.ListOptions) (runtime.Object, error) {
+			return kubeClient.CoreV1().Secrets("test").List(options)
+		},
+		WatchFunc: func(options meta.ListOptions) (watch.Interface, error) {
+			w, err := kubeClient.CoreV1().Secrets("test").Watch(options)
+			if err != nil {
+				return nil, err
+			}
+			// unlike the real client, the fake client sends the objects it stores
+			return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+				event.Object = event.Object.DeepCopyObject()
+				return event, true
+			}), nil
+		},
+	}
+	informer := NewTransformingInformer(lw, &v1.Secret{}, 0, ChainTransforms(StripData, StripManagedFields, StripAnnotations(v1.LastAppliedConfigAnnotation)))
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go informer.Run(ctx.Done())
+
+	synced := make(chan *v1.Secret, 10)
+	controller := NewFactory().Informers(informer).Sync(func(ctx context.Context, controllerContext Context) error {
+		synced <- controllerContext.GetQueueObject().(*v1.Secret)
+		return nil
+	}).Controller("TransformController", events.NewInMemoryRecorder("transform-controller"))
+	go controller.Run(ctx, 1)
+
+	expectStripped := func(updated bool) {
+		for {
+			select {
+			case secret := <-synced:
+				if updated && secret.Labels["updated"] != "true" {
+					continue
+				}
+				if len(secret.Data) > 0 || len(secret.ManagedFields) > 0 {
+					t.Errorf("expected data and managed fields to be stripped, got %#v", secret)
+				}
+				if _, ok := secret.Annotations[v1.LastAppliedConfigAnnotation]; ok || secret.Annotations["small"] != "kept" {
+					t.Errorf("expected only the last applied annotation to be stripped, got %v", secret.Annotations)
+				}
+				return
+			case <-time.After(10 * time.Second):
+				t.Fatal("test timeout")
+			}
+		}
+	}
+
+	// the object was listed
+	expectStripped(false)
+
+	// the object was observed by watch
+	secret.Labels = map[string]string{"updated": "true"}
+	secret.Data = map[string][]byte{"test": []byte("updated")}
+	if _, err := kubeClient.CoreV1().Secrets("test").Update(secret); err != nil {
+		t.Fatal(err)
+	}
+	expectStripped(true)
+}
+
+func TestFactoryTransform(t *testing.T) {
+	configMap := makeUnstructuredConfigMap("test", "test-config", nil)
+	configMap.Object["data"] = map[string]interface{}{"key": "value"}
+	configMap.SetManagedFields([]meta.ManagedFieldsEntry{{Manager: "kubectl"}})
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), configMap)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	synced := make(chan *unstructured.Unstructured, 10)
+	controller := NewFactory().
+		DynamicInformers(client, time.Minute, v1.SchemeGroupVersion.WithResource("configmaps")).
+		ForNamespaces("test").
+		WithInformersContext(ctx).
+		WithTransform(StripData).
+		WithTransform(StripManagedFields).
+		Sync(func(ctx context.Context, controllerContext Context) error {
+			synced <- controllerContext.GetQueueObject().(*unstructured.Unstructured)
+			return nil
+		}).Controller("FactoryTransformController", events.NewInMemoryRecorder("factory-transform-controller"))
+	go controller.Run(ctx, 1)
+
+	select {
+	case configMap := <-synced:
+		if _, ok := configMap.Object["data"]; ok || configMap.GetName() != "test-config" || len(configMap.GetManagedFields()) > 0 {
+			t.Errorf("expected data and managed fields to be stripped by both transforms, got %#v", configMap)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("test timeout")
+	}
+}
+
+func TestStripTransforms(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":        "test",
+			"annotations": map[string]interface{}{"large": "0123456789", "small": "0"},
+		},
+		"data":       map[string]interface{}{"a": "b"},
+		"binaryData": map[string]interface{}{"c": "ZA=="},
+	}}
+	result := ChainTransforms(StripData, StripLargeAnnotations(5))(obj).(*unstructured.Unstructured)
+	if _, ok := result.Object["data"]; ok {
+		t.Errorf("expected data to be stripped, got %v", result.Object)
+	}
+	if _, ok := result.Object["binaryData"]; ok {
+		t.Errorf("expected binary data to be stripped, got %v", result.Object)
+	}
+	if annotations := result.GetAnnotations(); len(annotations) != 1 || annotations["small"] != "0" {
+		t.Errorf("expected only the large annotation to be stripped, got %v", annotations)
+	}
+
+	configMap := &v1.ConfigMap{ObjectMeta: meta.ObjectMeta{Annotations: map[string]string{"a": "b"}}, Data: map[string]string{"a": "b"}}
+	StripAnnotations("a")(StripData(configMap))
+	if configMap.Data != nil || configMap.Annotations != nil {
+		t.Errorf("expected data and annotations to be stripped, got %#v", configMap)
+	}
+}
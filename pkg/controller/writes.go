@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DefaultWriteObservationTimeout is how long the controller waits for the informer to observe the object written by
+// Sync() before it syncs the informer copy anyway.
+const DefaultWriteObservationTimeout = 30 * time.Second
+
+// writtenVersion is the resourceVersion of the object written by Sync().
+type writtenVersion struct {
+	// resourceVersion is the written version, or the latest version delivered by the informer after it observed the
+	// written one.
+	resourceVersion string
+	expires         time.Time
+	// observed is set when the informer delivered the written version, every other copy of the object in the queue
+	// is then dropped until the write expires, because the informer already queued the newer one.
+	observed bool
+}
+
+// writeTracker remembers the resourceVersions of the objects written by Sync() per object, so the controller does not
+// sync the stale informer copies of the objects it has just written.
+// The resourceVersions are compared only for equality, as they must be treated as opaque. When the informer never
+// delivers the written version (for example, it was replaced by another write before the informer relisted), the
+// stale copy is synced after the timeout. The writes of objects the controller never syncs are pruned after the timeout.
+type writeTracker struct {
+	timeout   time.Duration
+	now       func() time.Time
+	writes    map[string]*writtenVersion
+	lastPrune time.Time
+	lock      sync.Mutex
+}
+
+func newWriteTracker(timeout time.Duration) *writeTracker {
+	return &writeTracker{
+		timeout: timeout,
+		now:     time.Now,
+		writes:  map[string]*writtenVersion{},
+	}
+}
+
+// written records the resourceVersion of the object returned by the create or update call for the object key.
+func (t *writeTracker) written(key, resourceVersion string) {
+	if len(key) == 0 || len(resourceVersion) == 0 {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	now := t.now()
+	if now.Sub(t.lastPrune) >= t.timeout {
+		for key, write := range t.writes {
+			if !now.Before(write.expires) {
+				delete(t.writes, key)
+			}
+		}
+		t.lastPrune = now
+	}
+	t.writes[key] = &writtenVersion{resourceVersion: resourceVersion, expires: now.Add(t.timeout)}
+}
+
+// observe is called for every object delivered by the informer. It marks the written version as observed, or forgets
+// it when the object was deleted. The informer delivers the versions in order, so once the written version was
+// observed, every version delivered later is newer and is not stale.
+func (t *writeTracker) observe(obj runtime.Object, eventType string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key := objectKeyFor(obj)
+	write, ok := t.writes[key]
+	if !ok {
+		return
+	}
+	switch {
+	case eventType == SyncEventDelete:
+		delete(t.writes, key)
+	case write.observed && len(resourceVersionOf(obj)) > 0:
+		write.resourceVersion = resourceVersionOf(obj)
+	case write.resourceVersion == resourceVersionOf(obj):
+		write.observed = true
+	}
+}
+
+// stale returns true when the queued object is older than the version written by Sync() and should not be synced.
+// The returned duration is how long to wait before the object is checked again. It is zero when the informer has
+// already observed the write, because the newer copy is queued by then. The write is kept until it expires, so the
+// stale copies that were put back to the queue before the write was observed are dropped as well.
+func (t *writeTracker) stale(obj runtime.Object) (time.Duration, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key := objectKeyFor(obj)
+	write, ok := t.writes[key]
+	if !ok {
+		return 0, false
+	}
+	now := t.now()
+	if !now.Before(write.expires) {
+		// the informer did not deliver the written object in time
+		delete(t.writes, key)
+		return 0, false
+	}
+	if write.resourceVersion == resourceVersionOf(obj) {
+		// the sync sees the written object
+		return 0, false
+	}
+	if write.observed {
+		return 0, true
+	}
+	return write.expires.Sub(now), true
+}
+
+// resourceVersionOf returns the resourceVersion of the object or empty string when the object has no metadata.
+func resourceVersionOf(obj runtime.Object) string {
+	obj, _ = unwrapClusterObject(obj)
+	if obj == nil {
+		return ""
+	}
+	metaObj, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return metaObj.GetResourceVersion()
+}
+
+// RecordWrite records the object returned by the create or update call made in Sync(). Until the informer observes
+// it, the stale copies of the object are not synced. The object is tagged with the cluster of the synced object.
+// The object must be of the same type as the objects delivered by the informer, so it is matched by kind.
+func (c controllerContext) RecordWrite(obj runtime.Object) {
+	if c.writes == nil || obj == nil {
+		return
+	}
+	c.writes.written(objectKeyFor(withCluster(obj, c.ClusterName())), resourceVersionOf(obj))
+}
+
+// isQueueObject returns true when the applied object is the object being synced.
+func (c controllerContext) isQueueObject(obj *unstructured.Unstructured) bool {
+	queueObject, _ := unwrapClusterObject(c.queueObject)
+	if queueObject == nil || queueKeyFor(withCluster(obj, c.ClusterName())) != queueKeyFor(c.queueObject) {
+		return false
+	}
+	return isSameKind(queueObject, obj)
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func secretWithVersion(resourceVersion string) *v1.Secret {
+	secret := makeFakeSecret()
+	secret.ResourceVersion = resourceVersion
+	return secret
+}
+
+func TestWriteTracker(t *testing.T) {
+	now := time.Now()
+	tracker := newWriteTracker(10 * time.Second)
+	tracker.now = func() time.Time { return now }
+	written := func(obj runtime.Object) {
+		tracker.written(objectKeyFor(obj), resourceVersionOf(obj))
+	}
+
+	if _, stale := tracker.stale(secretWithVersion("1")); stale {
+		t.Fatalf("expected object without recorded write to not be stale")
+	}
+
+	written(secretWithVersion("2"))
+	now = now.Add(4 * time.Second)
+	delay, stale := tracker.stale(secretWithVersion("1"))
+	if !stale || delay != 6*time.Second {
+		t.Errorf("expected stale object to be rechecked after 6s, got stale=%v after %v", stale, delay)
+	}
+
+	tracker.observe(secretWithVersion("2"), SyncEventUpdate)
+	if delay, stale := tracker.stale(secretWithVersion("1")); !stale || delay != 0 {
+		t.Errorf("expected stale object to be dropped once the write was observed, got stale=%v after %v", stale, delay)
+	}
+	if _, stale := tracker.stale(secretWithVersion("2")); stale {
+		t.Errorf("expected written version to not be stale")
+	}
+	// the stale copy put back to the queue before the write was observed is dropped after the delay
+	now = now.Add(delay - time.Millisecond)
+	if _, stale := tracker.stale(secretWithVersion("1")); !stale {
+		t.Errorf("expected the delayed stale object to be dropped after the written version was synced")
+	}
+	// the versions delivered after the written one are newer
+	tracker.observe(secretWithVersion("3"), SyncEventUpdate)
+	if _, stale := tracker.stale(secretWithVersion("3")); stale {
+		t.Errorf("expected the version delivered after the written one to not be stale")
+	}
+	if _, stale := tracker.stale(secretWithVersion("2")); !stale {
+		t.Errorf("expected the written version to be stale once newer version was delivered")
+	}
+	now = now.Add(time.Millisecond)
+	if _, stale := tracker.stale(secretWithVersion("1")); stale {
+		t.Errorf("expected the write to be forgotten after it expired")
+	}
+
+	// the informer never delivers the written version
+	written(secretWithVersion("3"))
+	now = now.Add(10 * time.Second)
+	if _, stale := tracker.stale(secretWithVersion("2")); stale {
+		t.Errorf("expected stale object to be synced after the timeout")
+	}
+
+	written(secretWithVersion("4"))
+	tracker.observe(secretWithVersion("3"), SyncEventDelete)
+	if _, stale := tracker.stale(secretWithVersion("3")); stale {
+		t.Errorf("expected the write to be forgotten when the object was deleted")
+	}
+
+	// objects with the same name in different clusters are tracked separately
+	written(withCluster(secretWithVersion("5"), "spoke"))
+	if _, stale := tracker.stale(secretWithVersion("1")); stale {
+		t.Errorf("expected the write in other cluster to not affect the object")
+	}
+
+	// objects of other kinds with the same name are tracked separately
+	written(&v1.ConfigMap{ObjectMeta: meta.ObjectMeta{Namespace: "test", Name: "test-secret", ResourceVersion: "6"}})
+	if _, stale := tracker.stale(secretWithVersion("1")); stale {
+		t.Errorf("expected the write of other kind to not affect the object")
+	}
+
+	// the writes that are never synced are pruned
+	now = now.Add(10 * time.Second)
+	written(secretWithVersion("7"))
+	if len(tracker.writes) != 1 {
+		t.Errorf("expected the expired writes to be pruned, got %d writes", len(tracker.writes))
+	}
+}
+
+func TestRecordWriteSkipsStaleSyncs(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(makeFakeSecret())
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 1*time.Minute, informers.WithNamespace("test"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go kubeInformers.Start(ctx.Done())
+
+	var lock sync.Mutex
+	var syncedVersions []string
+	controller := NewFactory().Informers(kubeInformers.Core().V1().Secrets().Informer()).Sync(func(ctx context.Context, controllerContext Context) error {
+		secret := controllerContext.GetQueueObject().(*v1.Secret)
+		lock.Lock()
+		syncedVersions = append(syncedVersions, secret.ResourceVersion)
+		lock.Unlock()
+		if secret.Labels["synced"] == "true" {
+			return nil
+		}
+
+		secret.Labels = map[string]string{"synced": "true"}
+		// the fake client does not bump the resourceVersion
+		secret.ResourceVersion = "2"
+		updated, err := kubeClient.CoreV1().Secrets("test").Update(secret)
+		if err != nil {
+			return err
+		}
+		controllerContext.RecordWrite(updated)
+		// queue the stale copy, as the informer resync or the retry would do
+		controllerContext.Queue().Add(controllerContext.GetQueueObject())
+		return nil
+	}).Controller("WritesController", events.NewInMemoryRecorder("writes-controller"))
+	go controller.Run(ctx, 1)
+
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(syncedVersions) == 2, nil
+	}); err != nil {
+		t.Fatalf("expected the written object to be synced, got syncs of versions %v", syncedVersions)
+	}
+
+	// give the controller time to sync the stale copy
+	time.Sleep(500 * time.Millisecond)
+	lock.Lock()
+	defer lock.Unlock()
+	if len(syncedVersions) != 2 || syncedVersions[0] != "" || syncedVersions[1] != "2" {
+		t.Errorf("expected the stale copy to not be synced, got syncs of versions %v", syncedVersions)
+	}
+
+	secret, err := kubeClient.CoreV1().Secrets("test").Get("test-secret", meta.GetOptions{})
+	if err != nil || secret.Labels["synced"] != "true" {
+		t.Errorf("expected the secret to be updated, got %v: %v", secret, err)
+	}
+}
@@ -0,0 +1,83 @@
+package resourceapply
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1client "k8s.io/client-go/kubernetes/typed/apps/v1"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// SpecHashAnnotation holds the hash of the required spec of the applied deployment.
+// The server defaults many fields of the deployment spec, so the required spec cannot be compared with the existing
+// spec directly. The hash detects the fields removed from the required spec, which the comparison of the fields set in
+// the required spec with the existing spec does not.
+const SpecHashAnnotation = "controller-factory/spec-hash"
+
+// ApplyDeployment creates or updates the deployment. The spec is replaced with the required spec when it changed since
+// the last apply or when the fields set in the required spec were changed by others. When the required spec does not set
+// the replicas, the existing replicas are kept, so the deployment can be scaled by others, for example by the horizontal
+// pod autoscaler.
+func ApplyDeployment(client appsv1client.DeploymentsGetter, recorder events.Recorder, required *appsv1.Deployment) (*appsv1.Deployment, bool, error) {
+	required = required.DeepCopy()
+	specHash, err := hashOf(required.Spec)
+	if err != nil {
+		return nil, false, err
+	}
+	if required.Annotations == nil {
+		required.Annotations = map[string]string{}
+	}
+	required.Annotations[SpecHashAnnotation] = specHash
+
+	existing, err := client.Deployments(required.Namespace).Get(required.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		actual, err := client.Deployments(required.Namespace).Create(required)
+		reportCreate(recorder, "Deployment", required.ObjectMeta, err)
+		return actual, err == nil, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	existingCopy := existing.DeepCopy()
+	// the spec hash annotation change is reported as spec change
+	requiredMeta := required.ObjectMeta.DeepCopy()
+	delete(requiredMeta.Annotations, SpecHashAnnotation)
+	changed := mergeObjectMeta(&existingCopy.ObjectMeta, *requiredMeta)
+	// the fields not set in the required spec are defaulted by the server and ignored, the replicas are compared below
+	requiredSpec := required.Spec.DeepCopy()
+	requiredSpec.Replicas = existing.Spec.Replicas
+	if existing.Annotations[SpecHashAnnotation] != specHash || !equality.Semantic.DeepDerivative(*requiredSpec, existing.Spec) {
+		if existingCopy.Annotations == nil {
+			existingCopy.Annotations = map[string]string{}
+		}
+		existingCopy.Annotations[SpecHashAnnotation] = specHash
+		existingCopy.Spec = *requiredSpec
+		changed.add(true, "spec")
+	}
+	if required.Spec.Replicas != nil && (existingCopy.Spec.Replicas == nil || *existingCopy.Spec.Replicas != *required.Spec.Replicas) {
+		existingCopy.Spec.Replicas = required.Spec.Replicas
+		changed.add(true, fmt.Sprintf("spec.replicas (%d)", *required.Spec.Replicas))
+	}
+	if len(changed) == 0 {
+		return existing, false, nil
+	}
+
+	actual, err := client.Deployments(required.Namespace).Update(existingCopy)
+	reportUpdate(recorder, "Deployment", required.ObjectMeta, changed, err)
+	return actual, err == nil, err
+}
+
+func hashOf(obj interface{}) (string, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
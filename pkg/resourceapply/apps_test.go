@@ -0,0 +1,85 @@
+package resourceapply
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func TestApplyDeployment(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	recorder := events.NewInMemoryRecorder("test")
+	required := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "test-deployment"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "test", Image: "test:v1"}}},
+			},
+		},
+	}
+
+	if _, changed, err := ApplyDeployment(client.AppsV1(), recorder, required); err != nil || !changed {
+		t.Fatalf("expected deployment to be created, got changed=%t, err=%v", changed, err)
+	}
+
+	// the deployment is scaled and defaulted by others
+	existing, _ := client.AppsV1().Deployments("test").Get("test-deployment", metav1.GetOptions{})
+	replicas := int32(3)
+	existing.Spec.Replicas = &replicas
+	existing.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyAlways
+	if _, err := client.AppsV1().Deployments("test").Update(existing); err != nil {
+		t.Fatal(err)
+	}
+	if _, changed, err := ApplyDeployment(client.AppsV1(), recorder, required); err != nil || changed {
+		t.Fatalf("expected no change, got changed=%t, err=%v", changed, err)
+	}
+
+	required.Spec.Template.Spec.Containers[0].Image = "test:v2"
+	actual, changed, err := ApplyDeployment(client.AppsV1(), recorder, required)
+	if err != nil || !changed {
+		t.Fatalf("expected deployment to be updated, got changed=%t, err=%v", changed, err)
+	}
+	if *actual.Spec.Replicas != 3 || actual.Spec.Template.Spec.Containers[0].Image != "test:v2" {
+		t.Errorf("expected image to be updated and replicas preserved, got %#v", actual.Spec)
+	}
+	if event := lastEvent(t, recorder); event != `DeploymentUpdated: Updated Deployment "test/test-deployment" because it changed: spec` {
+		t.Errorf("unexpected event: %s", event)
+	}
+
+	// the fields set in the required spec are reverted when changed by others
+	existing, _ = client.AppsV1().Deployments("test").Get("test-deployment", metav1.GetOptions{})
+	existing.Spec.Template.Spec.Containers[0].Image = "test:modified"
+	if _, err := client.AppsV1().Deployments("test").Update(existing); err != nil {
+		t.Fatal(err)
+	}
+	actual, changed, err = ApplyDeployment(client.AppsV1(), recorder, required)
+	if err != nil || !changed {
+		t.Fatalf("expected modified deployment to be updated, got changed=%t, err=%v", changed, err)
+	}
+	if *actual.Spec.Replicas != 3 || actual.Spec.Template.Spec.Containers[0].Image != "test:v2" {
+		t.Errorf("expected image to be reverted and replicas preserved, got %#v", actual.Spec)
+	}
+
+	// the required replicas are enforced
+	requiredReplicas := int32(1)
+	required.Spec.Replicas = &requiredReplicas
+	if actual, _, _ := ApplyDeployment(client.AppsV1(), recorder, required); *actual.Spec.Replicas != 1 {
+		t.Errorf("expected replicas to be set, got %d", *actual.Spec.Replicas)
+	}
+	existing, _ = client.AppsV1().Deployments("test").Get("test-deployment", metav1.GetOptions{})
+	existing.Spec.Replicas = &replicas
+	if _, err := client.AppsV1().Deployments("test").Update(existing); err != nil {
+		t.Fatal(err)
+	}
+	if _, changed, _ := ApplyDeployment(client.AppsV1(), recorder, required); !changed {
+		t.Errorf("expected scaled deployment to be reverted to required replicas")
+	}
+	if event := lastEvent(t, recorder); event != `DeploymentUpdated: Updated Deployment "test/test-deployment" because it changed: spec.replicas (1)` {
+		t.Errorf("unexpected event: %s", event)
+	}
+}
@@ -0,0 +1,197 @@
+// Package resourceapply provides create-or-update helpers for the core Kubernetes types to be used inside the controller
+// Sync() function, for example:
+//
+//	secret, changed, err := resourceapply.ApplySecret(kubeClient.CoreV1(), controllerContext.Events(), requiredSecret)
+//
+// The helpers create the object when it is missing, otherwise they update only the fields the controller manages and
+// skip the update when the object already matches. Labels, annotations and owner references set by others are preserved.
+// Every create or update is reported as an event describing what changed.
+package resourceapply
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// ApplySecret creates or updates the secret. The data (including the string data) are replaced with the required data.
+// The secret type cannot be changed, so the secret is re-created when the required type differs.
+func ApplySecret(client corev1client.SecretsGetter, recorder events.Recorder, required *corev1.Secret) (*corev1.Secret, bool, error) {
+	required = required.DeepCopy()
+	// the string data are merged to data by the server
+	for key, value := range required.StringData {
+		if required.Data == nil {
+			required.Data = map[string][]byte{}
+		}
+		required.Data[key] = []byte(value)
+	}
+	required.StringData = nil
+
+	existing, err := client.Secrets(required.Namespace).Get(required.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		actual, err := client.Secrets(required.Namespace).Create(required)
+		reportCreate(recorder, "Secret", required.ObjectMeta, err)
+		return actual, err == nil, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(required.Type) > 0 && required.Type != existing.Type {
+		if err := client.Secrets(required.Namespace).Delete(required.Name, &metav1.DeleteOptions{}); err != nil {
+			return nil, false, err
+		}
+		actual, err := client.Secrets(required.Namespace).Create(required)
+		reportCreate(recorder, "Secret", required.ObjectMeta, err)
+		return actual, err == nil, err
+	}
+
+	existingCopy := existing.DeepCopy()
+	changed := mergeObjectMeta(&existingCopy.ObjectMeta, required.ObjectMeta)
+	if !equality.Semantic.DeepEqual(existingCopy.Data, required.Data) {
+		changed.add(true, describeDataChanges("data", bytesToStrings(existingCopy.Data), bytesToStrings(required.Data)))
+		existingCopy.Data = required.Data
+	}
+	if len(changed) == 0 {
+		return existing, false, nil
+	}
+
+	actual, err := client.Secrets(required.Namespace).Update(existingCopy)
+	reportUpdate(recorder, "Secret", required.ObjectMeta, changed, err)
+	return actual, err == nil, err
+}
+
+// ApplyConfigMap creates or updates the config map. The data and binary data are replaced with the required data.
+func ApplyConfigMap(client corev1client.ConfigMapsGetter, recorder events.Recorder, required *corev1.ConfigMap) (*corev1.ConfigMap, bool, error) {
+	existing, err := client.ConfigMaps(required.Namespace).Get(required.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		actual, err := client.ConfigMaps(required.Namespace).Create(required.DeepCopy())
+		reportCreate(recorder, "ConfigMap", required.ObjectMeta, err)
+		return actual, err == nil, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	existingCopy := existing.DeepCopy()
+	changed := mergeObjectMeta(&existingCopy.ObjectMeta, required.ObjectMeta)
+	if !equality.Semantic.DeepEqual(existingCopy.Data, required.Data) {
+		changed.add(true, describeDataChanges("data", existingCopy.Data, required.Data))
+		existingCopy.Data = required.Data
+	}
+	if !equality.Semantic.DeepEqual(existingCopy.BinaryData, required.BinaryData) {
+		changed.add(true, describeDataChanges("binaryData", bytesToStrings(existingCopy.BinaryData), bytesToStrings(required.BinaryData)))
+		existingCopy.BinaryData = required.BinaryData
+	}
+	if len(changed) == 0 {
+		return existing, false, nil
+	}
+
+	actual, err := client.ConfigMaps(required.Namespace).Update(existingCopy)
+	reportUpdate(recorder, "ConfigMap", required.ObjectMeta, changed, err)
+	return actual, err == nil, err
+}
+
+// ApplyServiceAccount creates or updates the service account. Only the metadata and the token automount are managed,
+// the secrets and image pull secrets are owned by the token controller and are preserved.
+func ApplyServiceAccount(client corev1client.ServiceAccountsGetter, recorder events.Recorder, required *corev1.ServiceAccount) (*corev1.ServiceAccount, bool, error) {
+	existing, err := client.ServiceAccounts(required.Namespace).Get(required.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		actual, err := client.ServiceAccounts(required.Namespace).Create(required.DeepCopy())
+		reportCreate(recorder, "ServiceAccount", required.ObjectMeta, err)
+		return actual, err == nil, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	existingCopy := existing.DeepCopy()
+	changed := mergeObjectMeta(&existingCopy.ObjectMeta, required.ObjectMeta)
+	if required.AutomountServiceAccountToken != nil && !equality.Semantic.DeepEqual(existingCopy.AutomountServiceAccountToken, required.AutomountServiceAccountToken) {
+		existingCopy.AutomountServiceAccountToken = required.AutomountServiceAccountToken
+		changed.add(true, "automountServiceAccountToken")
+	}
+	if len(changed) == 0 {
+		return existing, false, nil
+	}
+
+	actual, err := client.ServiceAccounts(required.Namespace).Update(existingCopy)
+	reportUpdate(recorder, "ServiceAccount", required.ObjectMeta, changed, err)
+	return actual, err == nil, err
+}
+
+// ApplyService creates or updates the service. The spec is replaced with the required spec, except the cluster IP, node
+// ports, health check node port and defaulted fields that are kept when they are not set in the required spec.
+func ApplyService(client corev1client.ServicesGetter, recorder events.Recorder, required *corev1.Service) (*corev1.Service, bool, error) {
+	existing, err := client.Services(required.Namespace).Get(required.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		actual, err := client.Services(required.Namespace).Create(required.DeepCopy())
+		reportCreate(recorder, "Service", required.ObjectMeta, err)
+		return actual, err == nil, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	existingCopy := existing.DeepCopy()
+	changed := mergeObjectMeta(&existingCopy.ObjectMeta, required.ObjectMeta)
+
+	requiredSpec := required.Spec.DeepCopy()
+	// the fields allocated or defaulted by the server
+	if len(requiredSpec.ClusterIP) == 0 {
+		requiredSpec.ClusterIP = existing.Spec.ClusterIP
+	}
+	if len(requiredSpec.Type) == 0 {
+		requiredSpec.Type = existing.Spec.Type
+	}
+	if len(requiredSpec.SessionAffinity) == 0 {
+		requiredSpec.SessionAffinity = existing.Spec.SessionAffinity
+	}
+	if len(requiredSpec.ExternalTrafficPolicy) == 0 {
+		requiredSpec.ExternalTrafficPolicy = existing.Spec.ExternalTrafficPolicy
+	}
+	// the health check node port is allocated only for the load balancer services with the local traffic policy
+	if requiredSpec.HealthCheckNodePort == 0 && requiredSpec.Type == corev1.ServiceTypeLoadBalancer &&
+		requiredSpec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeLocal {
+		requiredSpec.HealthCheckNodePort = existing.Spec.HealthCheckNodePort
+	}
+	if requiredSpec.SessionAffinityConfig == nil && requiredSpec.SessionAffinity == existing.Spec.SessionAffinity {
+		requiredSpec.SessionAffinityConfig = existing.Spec.SessionAffinityConfig
+	}
+	if requiredSpec.IPFamily == nil {
+		requiredSpec.IPFamily = existing.Spec.IPFamily
+	}
+	for i := range requiredSpec.Ports {
+		for _, existingPort := range existing.Spec.Ports {
+			if existingPort.Name != requiredSpec.Ports[i].Name || existingPort.Port != requiredSpec.Ports[i].Port {
+				continue
+			}
+			if requiredSpec.Ports[i].NodePort == 0 {
+				requiredSpec.Ports[i].NodePort = existingPort.NodePort
+			}
+			if len(requiredSpec.Ports[i].Protocol) == 0 {
+				requiredSpec.Ports[i].Protocol = existingPort.Protocol
+			}
+			if requiredSpec.Ports[i].TargetPort.IntVal == 0 && len(requiredSpec.Ports[i].TargetPort.StrVal) == 0 {
+				requiredSpec.Ports[i].TargetPort = existingPort.TargetPort
+			}
+		}
+	}
+	var specChanged changes
+	specChanged.add(!equality.Semantic.DeepEqual(existingCopy.Spec.Ports, requiredSpec.Ports), "spec.ports")
+	specChanged.add(!equality.Semantic.DeepEqual(existingCopy.Spec.Selector, requiredSpec.Selector), "spec.selector")
+	specChanged.add(len(specChanged) == 0 && !equality.Semantic.DeepEqual(existingCopy.Spec, *requiredSpec), "spec")
+	changed = append(changed, specChanged...)
+	existingCopy.Spec = *requiredSpec
+	if len(changed) == 0 {
+		return existing, false, nil
+	}
+
+	actual, err := client.Services(required.Namespace).Update(existingCopy)
+	reportUpdate(recorder, "Service", required.ObjectMeta, changed, err)
+	return actual, err == nil, err
+}
@@ -0,0 +1,207 @@
+package resourceapply
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// countActions returns the number of actions with the given verb done by the fake client.
+func countActions(client *fake.Clientset, verb string) int {
+	count := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == verb {
+			count++
+		}
+	}
+	return count
+}
+
+func lastEvent(t *testing.T, recorder events.InMemoryRecorder) string {
+	t.Helper()
+	recorded := recorder.Events()
+	if len(recorded) == 0 {
+		t.Fatalf("expected event to be recorded")
+	}
+	return recorded[len(recorded)-1].Reason + ": " + recorded[len(recorded)-1].Message
+}
+
+func TestApplySecret(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	recorder := events.NewInMemoryRecorder("test")
+	required := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "test-secret", Labels: map[string]string{"app": "test"}},
+		Data:       map[string][]byte{"password": []byte("secret")},
+		StringData: map[string]string{"user": "admin"},
+	}
+
+	if _, changed, err := ApplySecret(client.CoreV1(), recorder, required); err != nil || !changed {
+		t.Fatalf("expected secret to be created, got changed=%t, err=%v", changed, err)
+	}
+	if event := lastEvent(t, recorder); event != `SecretCreated: Created Secret "test/test-secret" because it was missing` {
+		t.Errorf("unexpected event: %s", event)
+	}
+
+	// label and data key added by others
+	existing, _ := client.CoreV1().Secrets("test").Get("test-secret", metav1.GetOptions{})
+	existing.Labels["owner"] = "someone"
+	existing.Data["user"] = []byte("admin")
+	if _, err := client.CoreV1().Secrets("test").Update(existing); err != nil {
+		t.Fatal(err)
+	}
+
+	client.ClearActions()
+	if _, changed, err := ApplySecret(client.CoreV1(), recorder, required); err != nil || changed {
+		t.Fatalf("expected no change, got changed=%t, err=%v", changed, err)
+	}
+	if updates := countActions(client, "update"); updates != 0 {
+		t.Errorf("expected no-op apply to skip update, got %d updates", updates)
+	}
+
+	required.Data = map[string][]byte{"password": []byte("changed"), "token": []byte("abc")}
+	required.StringData = nil
+	actual, changed, err := ApplySecret(client.CoreV1(), recorder, required)
+	if err != nil || !changed {
+		t.Fatalf("expected secret to be updated, got changed=%t, err=%v", changed, err)
+	}
+	if actual.Labels["owner"] != "someone" || actual.Labels["app"] != "test" {
+		t.Errorf("expected labels to be merged, got %v", actual.Labels)
+	}
+	if event := lastEvent(t, recorder); event != `SecretUpdated: Updated Secret "test/test-secret" because it changed: data (added token; changed password; removed user)` {
+		t.Errorf("unexpected event: %s", event)
+	}
+}
+
+func TestApplyConfigMap(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "test-config", Annotations: map[string]string{"other": "value"}},
+		Data:       map[string]string{"key": "old"},
+	})
+	recorder := events.NewInMemoryRecorder("test")
+	required := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "test-config", Annotations: map[string]string{"mine": "value"}},
+		Data:       map[string]string{"key": "new"},
+	}
+
+	actual, changed, err := ApplyConfigMap(client.CoreV1(), recorder, required)
+	if err != nil || !changed {
+		t.Fatalf("expected config map to be updated, got changed=%t, err=%v", changed, err)
+	}
+	if len(actual.Annotations) != 2 || actual.Data["key"] != "new" {
+		t.Errorf("unexpected config map: %#v", actual)
+	}
+	if event := lastEvent(t, recorder); event != `ConfigMapUpdated: Updated ConfigMap "test/test-config" because it changed: annotations, data (changed key)` {
+		t.Errorf("unexpected event: %s", event)
+	}
+	if _, changed, _ := ApplyConfigMap(client.CoreV1(), recorder, required); changed {
+		t.Errorf("expected second apply to be no-op")
+	}
+}
+
+func TestApplyServiceAccount(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "test-sa"},
+		Secrets:    []corev1.ObjectReference{{Name: "test-sa-token"}},
+	})
+	recorder := events.NewInMemoryRecorder("test")
+	required := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "test-sa"}}
+
+	actual, changed, err := ApplyServiceAccount(client.CoreV1(), recorder, required)
+	if err != nil || changed {
+		t.Fatalf("expected no change, got changed=%t, err=%v", changed, err)
+	}
+	if len(actual.Secrets) != 1 {
+		t.Errorf("expected token secrets to be preserved, got %v", actual.Secrets)
+	}
+}
+
+func TestApplyService(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "test-service"},
+		Spec: corev1.ServiceSpec{
+			Type:            corev1.ServiceTypeNodePort,
+			ClusterIP:       "10.0.0.1",
+			SessionAffinity: corev1.ServiceAffinityNone,
+			Selector:        map[string]string{"app": "test"},
+			Ports: []corev1.ServicePort{{
+				Name: "https", Port: 443, Protocol: corev1.ProtocolTCP, TargetPort: intstr.FromInt(443), NodePort: 30443,
+			}},
+		},
+	})
+	recorder := events.NewInMemoryRecorder("test")
+	required := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "test-service"},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": "test"},
+			Ports:    []corev1.ServicePort{{Name: "https", Port: 443}},
+		},
+	}
+
+	// the fields allocated or defaulted by the server are not reverted
+	if _, changed, err := ApplyService(client.CoreV1(), recorder, required); err != nil || changed {
+		t.Fatalf("expected no change, got changed=%t, err=%v", changed, err)
+	}
+
+	required.Spec.Selector = map[string]string{"app": "other"}
+	actual, changed, err := ApplyService(client.CoreV1(), recorder, required)
+	if err != nil || !changed {
+		t.Fatalf("expected service to be updated, got changed=%t, err=%v", changed, err)
+	}
+	if actual.Spec.ClusterIP != "10.0.0.1" || actual.Spec.Ports[0].NodePort != 30443 || actual.Spec.Selector["app"] != "other" {
+		t.Errorf("unexpected service spec: %#v", actual.Spec)
+	}
+	if event := lastEvent(t, recorder); event != `ServiceUpdated: Updated Service "test/test-service" because it changed: spec.selector` {
+		t.Errorf("unexpected event: %s", event)
+	}
+}
+
+func TestApplyLoadBalancerService(t *testing.T) {
+	ipFamily := corev1.IPv4Protocol
+	timeout := int32(10800)
+	client := fake.NewSimpleClientset(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "test-service"},
+		Spec: corev1.ServiceSpec{
+			Type:                  corev1.ServiceTypeLoadBalancer,
+			ClusterIP:             "10.0.0.1",
+			ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
+			HealthCheckNodePort:   31000,
+			SessionAffinity:       corev1.ServiceAffinityClientIP,
+			SessionAffinityConfig: &corev1.SessionAffinityConfig{ClientIP: &corev1.ClientIPConfig{TimeoutSeconds: &timeout}},
+			IPFamily:              &ipFamily,
+			Selector:              map[string]string{"app": "test"},
+			Ports: []corev1.ServicePort{{
+				Name: "https", Port: 443, Protocol: corev1.ProtocolTCP, TargetPort: intstr.FromInt(443), NodePort: 30443,
+			}},
+		},
+	})
+	recorder := events.NewInMemoryRecorder("test")
+	required := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "test-service"},
+		Spec: corev1.ServiceSpec{
+			Type:                  corev1.ServiceTypeLoadBalancer,
+			ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
+			SessionAffinity:       corev1.ServiceAffinityClientIP,
+			Selector:              map[string]string{"app": "test"},
+			Ports:                 []corev1.ServicePort{{Name: "https", Port: 443}},
+		},
+	}
+
+	// the health check node port and the defaulted fields are not reverted
+	if _, changed, err := ApplyService(client.CoreV1(), recorder, required); err != nil || changed {
+		t.Fatalf("expected no change, got changed=%t, err=%v", changed, err)
+	}
+
+	required.Spec.Selector = map[string]string{"app": "other"}
+	actual, changed, err := ApplyService(client.CoreV1(), recorder, required)
+	if err != nil || !changed {
+		t.Fatalf("expected service to be updated, got changed=%t, err=%v", changed, err)
+	}
+	if actual.Spec.HealthCheckNodePort != 31000 || actual.Spec.SessionAffinityConfig == nil || actual.Spec.IPFamily == nil {
+		t.Errorf("unexpected service spec: %#v", actual.Spec)
+	}
+}
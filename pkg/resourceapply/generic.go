@@ -0,0 +1,140 @@
+package resourceapply
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// changes collects the description of the fields changed by the apply.
+type changes []string
+
+func (c *changes) add(changed bool, description string) {
+	if changed {
+		*c = append(*c, description)
+	}
+}
+
+func (c changes) String() string {
+	return strings.Join(c, ", ")
+}
+
+// mergeObjectMeta merges the labels, annotations and owner references of the required object into the existing object.
+// Keys and owner references set by others are preserved. It returns the description of what changed.
+func mergeObjectMeta(existing *metav1.ObjectMeta, required metav1.ObjectMeta) changes {
+	var result changes
+	result.add(mergeMap(&existing.Labels, required.Labels), "labels")
+	result.add(mergeMap(&existing.Annotations, required.Annotations), "annotations")
+	result.add(mergeOwnerReferences(&existing.OwnerReferences, required.OwnerReferences), "ownerReferences")
+	return result
+}
+
+func mergeMap(existing *map[string]string, required map[string]string) bool {
+	changed := false
+	for key, value := range required {
+		if current, ok := (*existing)[key]; ok && current == value {
+			continue
+		}
+		if *existing == nil {
+			*existing = map[string]string{}
+		}
+		(*existing)[key] = value
+		changed = true
+	}
+	return changed
+}
+
+func mergeOwnerReferences(existing *[]metav1.OwnerReference, required []metav1.OwnerReference) bool {
+	changed := false
+	for _, requiredRef := range required {
+		found := false
+		for i := range *existing {
+			if (*existing)[i].UID != requiredRef.UID {
+				continue
+			}
+			found = true
+			if !equality.Semantic.DeepEqual((*existing)[i], requiredRef) {
+				(*existing)[i] = requiredRef
+				changed = true
+			}
+		}
+		if !found {
+			*existing = append(*existing, requiredRef)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// describeDataChanges describes which keys of the data map were added, changed or removed. The values are never
+// included, as they can be secret.
+func describeDataChanges(field string, existing, required map[string]string) string {
+	var added, changed, removed []string
+	for key, value := range required {
+		current, ok := existing[key]
+		switch {
+		case !ok:
+			added = append(added, key)
+		case current != value:
+			changed = append(changed, key)
+		}
+	}
+	for key := range existing {
+		if _, ok := required[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	var parts []string
+	for _, p := range []struct {
+		verb string
+		keys []string
+	}{{"added", added}, {"changed", changed}, {"removed", removed}} {
+		if len(p.keys) == 0 {
+			continue
+		}
+		sort.Strings(p.keys)
+		parts = append(parts, fmt.Sprintf("%s %s", p.verb, strings.Join(p.keys, ",")))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s)", field, strings.Join(parts, "; "))
+}
+
+func bytesToStrings(data map[string][]byte) map[string]string {
+	result := make(map[string]string, len(data))
+	for key, value := range data {
+		result[key] = string(value)
+	}
+	return result
+}
+
+func objectKey(meta metav1.ObjectMeta) string {
+	if len(meta.Namespace) == 0 {
+		return meta.Name
+	}
+	return meta.Namespace + "/" + meta.Name
+}
+
+// reportCreate records the result of creating the object.
+func reportCreate(recorder events.Recorder, kind string, meta metav1.ObjectMeta, err error) {
+	if err != nil {
+		recorder.Warningf(kind+"CreateFailed", "Failed to create %s %q: %v", kind, objectKey(meta), err)
+		return
+	}
+	recorder.Eventf(kind+"Created", "Created %s %q because it was missing", kind, objectKey(meta))
+}
+
+// reportUpdate records the result of updating the object together with the description of what changed.
+func reportUpdate(recorder events.Recorder, kind string, meta metav1.ObjectMeta, changed changes, err error) {
+	if err != nil {
+		recorder.Warningf(kind+"UpdateFailed", "Failed to update %s %q: %v", kind, objectKey(meta), err)
+		return
+	}
+	recorder.Eventf(kind+"Updated", "Updated %s %q because it changed: %s", kind, objectKey(meta), changed)
+}
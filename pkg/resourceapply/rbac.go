@@ -0,0 +1,152 @@
+package resourceapply
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	rbacv1client "k8s.io/client-go/kubernetes/typed/rbac/v1"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// ApplyClusterRole creates or updates the cluster role. The rules are replaced with the required rules, unless the
+// cluster role is aggregated. The rules of aggregated cluster roles are owned by the aggregation controller, so only
+// the aggregation rule is managed then.
+func ApplyClusterRole(client rbacv1client.ClusterRolesGetter, recorder events.Recorder, required *rbacv1.ClusterRole) (*rbacv1.ClusterRole, bool, error) {
+	existing, err := client.ClusterRoles().Get(required.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		actual, err := client.ClusterRoles().Create(required.DeepCopy())
+		reportCreate(recorder, "ClusterRole", required.ObjectMeta, err)
+		return actual, err == nil, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	existingCopy := existing.DeepCopy()
+	changed := mergeObjectMeta(&existingCopy.ObjectMeta, required.ObjectMeta)
+	if required.AggregationRule != nil {
+		if !equality.Semantic.DeepEqual(existingCopy.AggregationRule, required.AggregationRule) {
+			existingCopy.AggregationRule = required.AggregationRule
+			changed.add(true, "aggregationRule")
+		}
+	} else {
+		if existingCopy.AggregationRule != nil {
+			existingCopy.AggregationRule = nil
+			changed.add(true, "aggregationRule")
+		}
+		if !equality.Semantic.DeepEqual(existingCopy.Rules, required.Rules) {
+			existingCopy.Rules = required.Rules
+			changed.add(true, "rules")
+		}
+	}
+	if len(changed) == 0 {
+		return existing, false, nil
+	}
+
+	actual, err := client.ClusterRoles().Update(existingCopy)
+	reportUpdate(recorder, "ClusterRole", required.ObjectMeta, changed, err)
+	return actual, err == nil, err
+}
+
+// ApplyRole creates or updates the role. The rules are replaced with the required rules.
+func ApplyRole(client rbacv1client.RolesGetter, recorder events.Recorder, required *rbacv1.Role) (*rbacv1.Role, bool, error) {
+	existing, err := client.Roles(required.Namespace).Get(required.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		actual, err := client.Roles(required.Namespace).Create(required.DeepCopy())
+		reportCreate(recorder, "Role", required.ObjectMeta, err)
+		return actual, err == nil, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	existingCopy := existing.DeepCopy()
+	changed := mergeObjectMeta(&existingCopy.ObjectMeta, required.ObjectMeta)
+	if !equality.Semantic.DeepEqual(existingCopy.Rules, required.Rules) {
+		existingCopy.Rules = required.Rules
+		changed.add(true, "rules")
+	}
+	if len(changed) == 0 {
+		return existing, false, nil
+	}
+
+	actual, err := client.Roles(required.Namespace).Update(existingCopy)
+	reportUpdate(recorder, "Role", required.ObjectMeta, changed, err)
+	return actual, err == nil, err
+}
+
+// ApplyClusterRoleBinding creates or updates the cluster role binding. The subjects are replaced with the required
+// subjects. The role reference cannot be changed, so the binding is re-created when the required role reference differs.
+func ApplyClusterRoleBinding(client rbacv1client.ClusterRoleBindingsGetter, recorder events.Recorder, required *rbacv1.ClusterRoleBinding) (*rbacv1.ClusterRoleBinding, bool, error) {
+	existing, err := client.ClusterRoleBindings().Get(required.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		actual, err := client.ClusterRoleBindings().Create(required.DeepCopy())
+		reportCreate(recorder, "ClusterRoleBinding", required.ObjectMeta, err)
+		return actual, err == nil, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !equality.Semantic.DeepEqual(existing.RoleRef, required.RoleRef) {
+		if err := client.ClusterRoleBindings().Delete(required.Name, &metav1.DeleteOptions{}); err != nil {
+			return nil, false, err
+		}
+		actual, err := client.ClusterRoleBindings().Create(required.DeepCopy())
+		reportCreate(recorder, "ClusterRoleBinding", required.ObjectMeta, err)
+		return actual, err == nil, err
+	}
+
+	existingCopy := existing.DeepCopy()
+	changed := mergeObjectMeta(&existingCopy.ObjectMeta, required.ObjectMeta)
+	if !equality.Semantic.DeepEqual(existingCopy.Subjects, required.Subjects) {
+		existingCopy.Subjects = required.Subjects
+		changed.add(true, "subjects")
+	}
+	if len(changed) == 0 {
+		return existing, false, nil
+	}
+
+	actual, err := client.ClusterRoleBindings().Update(existingCopy)
+	reportUpdate(recorder, "ClusterRoleBinding", required.ObjectMeta, changed, err)
+	return actual, err == nil, err
+}
+
+// ApplyRoleBinding creates or updates the role binding. The subjects are replaced with the required subjects.
+// The role reference cannot be changed, so the binding is re-created when the required role reference differs.
+func ApplyRoleBinding(client rbacv1client.RoleBindingsGetter, recorder events.Recorder, required *rbacv1.RoleBinding) (*rbacv1.RoleBinding, bool, error) {
+	existing, err := client.RoleBindings(required.Namespace).Get(required.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		actual, err := client.RoleBindings(required.Namespace).Create(required.DeepCopy())
+		reportCreate(recorder, "RoleBinding", required.ObjectMeta, err)
+		return actual, err == nil, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !equality.Semantic.DeepEqual(existing.RoleRef, required.RoleRef) {
+		if err := client.RoleBindings(required.Namespace).Delete(required.Name, &metav1.DeleteOptions{}); err != nil {
+			return nil, false, err
+		}
+		actual, err := client.RoleBindings(required.Namespace).Create(required.DeepCopy())
+		reportCreate(recorder, "RoleBinding", required.ObjectMeta, err)
+		return actual, err == nil, err
+	}
+
+	existingCopy := existing.DeepCopy()
+	changed := mergeObjectMeta(&existingCopy.ObjectMeta, required.ObjectMeta)
+	if !equality.Semantic.DeepEqual(existingCopy.Subjects, required.Subjects) {
+		existingCopy.Subjects = required.Subjects
+		changed.add(true, "subjects")
+	}
+	if len(changed) == 0 {
+		return existing, false, nil
+	}
+
+	actual, err := client.RoleBindings(required.Namespace).Update(existingCopy)
+	reportUpdate(recorder, "RoleBinding", required.ObjectMeta, changed, err)
+	return actual, err == nil, err
+}
@@ -0,0 +1,73 @@
+package resourceapply
+
+import (
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func TestApplyClusterRole(t *testing.T) {
+	client := fake.NewSimpleClientset(&rbacv1.ClusterRole{
+		ObjectMeta:      metav1.ObjectMeta{Name: "aggregated"},
+		AggregationRule: &rbacv1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{{MatchLabels: map[string]string{"aggregate": "true"}}}},
+		Rules:           []rbacv1.PolicyRule{{Verbs: []string{"get"}, Resources: []string{"secrets"}}},
+	})
+	recorder := events.NewInMemoryRecorder("test")
+
+	// the rules of aggregated role are owned by the aggregation controller
+	required := &rbacv1.ClusterRole{
+		ObjectMeta:      metav1.ObjectMeta{Name: "aggregated"},
+		AggregationRule: &rbacv1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{{MatchLabels: map[string]string{"aggregate": "true"}}}},
+	}
+	if _, changed, err := ApplyClusterRole(client.RbacV1(), recorder, required); err != nil || changed {
+		t.Fatalf("expected no change, got changed=%t, err=%v", changed, err)
+	}
+
+	required.AggregationRule = nil
+	required.Rules = []rbacv1.PolicyRule{{Verbs: []string{"list"}, Resources: []string{"secrets"}}}
+	actual, changed, err := ApplyClusterRole(client.RbacV1(), recorder, required)
+	if err != nil || !changed {
+		t.Fatalf("expected cluster role to be updated, got changed=%t, err=%v", changed, err)
+	}
+	if actual.AggregationRule != nil || actual.Rules[0].Verbs[0] != "list" {
+		t.Errorf("unexpected cluster role: %#v", actual)
+	}
+	if event := lastEvent(t, recorder); event != `ClusterRoleUpdated: Updated ClusterRole "aggregated" because it changed: aggregationRule, rules` {
+		t.Errorf("unexpected event: %s", event)
+	}
+}
+
+func TestApplyRoleBinding(t *testing.T) {
+	client := fake.NewSimpleClientset(&rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "test-binding"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: "old"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test", Namespace: "test"}},
+	})
+	recorder := events.NewInMemoryRecorder("test")
+	required := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "test-binding"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: "new"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test", Namespace: "test"}},
+	}
+
+	// the role reference is immutable
+	actual, changed, err := ApplyRoleBinding(client.RbacV1(), recorder, required)
+	if err != nil || !changed {
+		t.Fatalf("expected role binding to be re-created, got changed=%t, err=%v", changed, err)
+	}
+	if actual.RoleRef.Name != "new" || countActions(client, "delete") != 1 {
+		t.Errorf("expected role binding to be re-created with new role, got %#v", actual)
+	}
+
+	required.Subjects = append(required.Subjects, rbacv1.Subject{Kind: "User", Name: "admin"})
+	if _, changed, err := ApplyRoleBinding(client.RbacV1(), recorder, required); err != nil || !changed {
+		t.Fatalf("expected role binding to be updated, got changed=%t, err=%v", changed, err)
+	}
+	if event := lastEvent(t, recorder); event != `RoleBindingUpdated: Updated RoleBinding "test/test-binding" because it changed: subjects` {
+		t.Errorf("unexpected event: %s", event)
+	}
+}